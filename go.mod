@@ -11,6 +11,7 @@ require (
 	github.com/danieldin95/lightstar v0.0.0-20200401145448-034e11afcf81 // indirect
 	github.com/docker/libnetwork v0.5.6 // indirect
 	github.com/godbus/dbus v4.1.0+incompatible // indirect
+	github.com/golang/snappy v0.0.1
 	github.com/gorilla/mux v1.7.4
 	github.com/moby/libnetwork v0.5.6
 	github.com/songgao/water v0.0.0-20190725173103-fd331bda3f4b
@@ -1,14 +1,19 @@
 package config
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/xtaci/kcp-go/v5"
+	"golang.org/x/crypto/pbkdf2"
 	"io/ioutil"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Queue struct {
@@ -51,6 +56,42 @@ func (q *Queue) Default() {
 	libol.Debug("Queue.Default %v", q)
 }
 
+type Batch struct {
+	Size  int `json:"size"`  // max bytes of frames coalesced into one write.
+	Delay int `json:"delay"` // max microseconds to wait for more frames.
+}
+
+func (b *Batch) Default() {
+	if b.Size == 0 {
+		b.Size = 16 * 1024
+	}
+	if b.Delay == 0 {
+		b.Delay = 500
+	}
+}
+
+// Get returns size and delay, and zero size if not configured.
+func (b *Batch) Get() (int, time.Duration) {
+	if b == nil {
+		return 0, 0
+	}
+	return b.Size, time.Duration(b.Delay) * time.Microsecond
+}
+
+type Keepalive struct {
+	Interval int `json:"interval"` // seconds to ping clients.
+	Timeout  int `json:"timeout"`  // seconds to evict client without any frame.
+}
+
+func (k *Keepalive) Default() {
+	if k.Interval == 0 {
+		k.Interval = 30
+	}
+	if k.Timeout == 0 {
+		k.Timeout = k.Interval * 3
+	}
+}
+
 type Log struct {
 	File    string `json:"file,omitempty"`
 	Verbose int    `json:"level,omitempty"`
@@ -61,9 +102,20 @@ type Http struct {
 	Public string `json:"public,omitempty"`
 }
 
+const MinSecret = 16 // length of secret for AEAD at least.
+
 type Crypt struct {
 	Algo   string `json:"algo,omitempty"`
 	Secret string `json:"secret,omitempty"`
+	Rekey  int    `json:"rekey,omitempty"` // seconds to exchange keys again.
+}
+
+// Correct returns error if secret is too short for AEAD algorithm.
+func (c *Crypt) Correct() error {
+	if libol.IsAead(c.Algo) && len(c.Secret) < MinSecret {
+		return libol.NewErr("secret of %s less than %d", c.Algo, MinSecret)
+	}
+	return nil
 }
 
 func (c *Crypt) IsZero() bool {
@@ -74,13 +126,19 @@ func (c *Crypt) Default() {
 	if c.Secret != "" && c.Algo == "" {
 		c.Algo = "xor"
 	}
+	if libol.IsAead(c.Algo) && c.Rekey == 0 {
+		c.Rekey = 3600
+	}
 }
 
+// RightAddr appends port if not given, and supports IPv6 address
+// as [2001:db8::1]:10002, [2001:db8::1] or 2001:db8::1.
 func RightAddr(listen *string, port int) {
-	values := strings.Split(*listen, ":")
-	if len(values) == 1 {
-		*listen = fmt.Sprintf("%s:%d", values[0], port)
+	if _, _, err := net.SplitHostPort(*listen); err == nil {
+		return
 	}
+	host := strings.TrimSuffix(strings.TrimPrefix(*listen, "["), "]")
+	*listen = net.JoinHostPort(host, strconv.Itoa(port))
 }
 
 func GetAlias() string {
@@ -91,14 +149,22 @@ func GetAlias() string {
 }
 
 type Cert struct {
-	Dir      string `json:"dir"`
-	CrtFile  string `json:"crt"`
-	KeyFile  string `json:"key"`
-	CaFile   string `json:"ca"`
-	Insecure bool   `json:"insecure"`
+	Dir        string `json:"dir"`
+	CrtFile    string `json:"crt"`
+	KeyFile    string `json:"key"`
+	CaFile     string `json:"ca"`
+	Insecure   bool   `json:"insecure"`
+	ClientCa   string `json:"clientCa,omitempty"`   // verify certificate of client by it.
+	ClientAuth string `json:"clientAuth,omitempty"` // cert or both, default is both.
+	// SHA-256 of public key of switch, and trust on first use if tofu.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Tofu        bool   `json:"tofu,omitempty"`
 }
 
 func (c *Cert) Right() {
+	if c.ClientCa != "" && c.ClientAuth == "" {
+		c.ClientAuth = "both"
+	}
 	if c.Dir == "" {
 		return
 	}
@@ -123,18 +189,51 @@ func (c *Cert) GetTlsCfg() *tls.Config {
 		libol.Error("Cert.GetTlsCfg: %s", err)
 		return nil
 	}
-	return &tls.Config{Certificates: []tls.Certificate{cer}}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cer}}
+	if c.ClientCa != "" {
+		cfg.ClientCAs = getCertPool(c.ClientCa)
+		if cfg.ClientCAs == nil { // not trust any client.
+			libol.Error("Cert.GetTlsCfg: %s not loaded", c.ClientCa)
+			cfg.ClientCAs = x509.NewCertPool()
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg
+}
+
+// GetCertificates returns certificate of client if key pair existed.
+func (c *Cert) GetCertificates() []tls.Certificate {
+	if !c.HasKeyPair() {
+		return nil
+	}
+	cer, err := tls.LoadX509KeyPair(c.CrtFile, c.KeyFile)
+	if err != nil {
+		libol.Error("Cert.GetCertificates: %s", err)
+		return nil
+	}
+	return []tls.Certificate{cer}
+}
+
+func (c *Cert) HasKeyPair() bool {
+	if c.KeyFile == "" || c.CrtFile == "" {
+		return false
+	}
+	return libol.FileExist(c.CrtFile) == nil && libol.FileExist(c.KeyFile) == nil
 }
 
 func (c *Cert) GetCertPool() *x509.CertPool {
-	if c.CaFile == "" {
+	return getCertPool(c.CaFile)
+}
+
+func getCertPool(file string) *x509.CertPool {
+	if file == "" {
 		return nil
 	}
-	if err := libol.FileExist(c.CaFile); err != nil {
-		libol.Debug("Cert.GetTlsCertPool: %s not such file", c.CaFile)
+	if err := libol.FileExist(file); err != nil {
+		libol.Debug("Cert.GetTlsCertPool: %s not such file", file)
 		return nil
 	}
-	caCert, err := ioutil.ReadFile(c.CaFile)
+	caCert, err := ioutil.ReadFile(file)
 	if err != nil {
 		libol.Warn("Cert.GetTlsCertPool: %s", err)
 		return nil
@@ -147,7 +246,7 @@ func (c *Cert) GetCertPool() *x509.CertPool {
 }
 
 func GetBlock(cfg *Crypt) kcp.BlockCrypt {
-	if cfg == nil || cfg.IsZero() {
+	if cfg == nil || cfg.IsZero() || libol.IsAead(cfg.Algo) {
 		return nil
 	}
 	var block kcp.BlockCrypt
@@ -167,3 +266,34 @@ func GetBlock(cfg *Crypt) kcp.BlockCrypt {
 	}
 	return block
 }
+
+// GetProxy returns nil if address of proxy is empty or invalid.
+func GetProxy(addr string) *libol.Proxy {
+	if addr == "" {
+		return nil
+	}
+	proxy, err := libol.NewProxy(addr)
+	if err != nil {
+		libol.Error("GetProxy: %s", err)
+		return nil
+	}
+	return proxy
+}
+
+// GetAead derives key from secret salted by network, and returns nil if not
+// an AEAD algorithm.
+func GetAead(cfg *Crypt, network string) *libol.AeadConfig {
+	if cfg == nil || !libol.IsAead(cfg.Algo) {
+		return nil
+	}
+	size := libol.AeadKeySize(cfg.Algo)
+	salt := []byte("openlan/" + network)
+	key := pbkdf2.Key([]byte(cfg.Secret), salt, 4096, size, sha256.New)
+	aead, err := libol.NewAeadConfig(cfg.Algo, key)
+	if err != nil {
+		libol.Error("GetAead: %s", err)
+		return nil
+	}
+	aead.Rekey = time.Duration(cfg.Rekey) * time.Second
+	return aead
+}
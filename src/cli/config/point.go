@@ -3,22 +3,85 @@ package config
 import (
 	"flag"
 	"github.com/danieldin95/openlan-go/src/libol"
+	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 )
 
+// pinLock guards fingerprints saved by switches probed at same time.
+var pinLock sync.Mutex
+
 type Interface struct {
 	Name     string `json:"name,omitempty"`
-	IfMtu    int    `json:"mtu"`
+	IfMtu    int    `json:"mtu"` // path MTU is probed under it only if protocol is udp.
 	Address  string `json:"address,omitempty"`
 	Bridge   string `json:"bridge,omitempty"`
 	Provider string `json:"provider,omitempty"`
 	Cost     int    `json:"cost,omitempty"`
 }
 
+// Join is other network joined by same connection.
+type Join struct {
+	Network   string    `json:"network"`
+	Password  string    `json:"password,omitempty"` // default is password of point.
+	Interface Interface `json:"interface"`
+}
+
+// Backup is other switch to fail over, and bigger weight is preferred.
+type Backup struct {
+	Connection  string `json:"connection"`
+	Weight      int    `json:"weight,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"` // SHA-256 of public key of it.
+}
+
+// Bond holds links to switches at same time, and spreads frames across
+// them by hash of flow, or sends by first link alive in backup mode.
+type Bond struct {
+	Mode    string   `json:"mode,omitempty"` // hash or backup.
+	Links   []string `json:"links"`
+	Latency int      `json:"latency,omitempty"` // ms, and a slower link is unhealthy.
+}
+
+func (b *Bond) Default() {
+	if b.Mode == "" {
+		b.Mode = "hash"
+	}
+	for i := range b.Links {
+		RightAddr(&b.Links[i], 10002)
+	}
+}
+
+// Select connects to switch of lowest latency in primary and backups, and
+// migrates if another is better by margin in times of probes in a row.
+type Select struct {
+	Interval int `json:"interval,omitempty"` // seconds to probe again.
+	Margin   int `json:"margin,omitempty"`   // ms.
+	Times    int `json:"times,omitempty"`
+}
+
+func (s *Select) Default() {
+	if s.Interval == 0 {
+		s.Interval = 60
+	}
+	if s.Margin == 0 {
+		s.Margin = 20
+	}
+	if s.Times == 0 {
+		s.Times = 3
+	}
+}
+
 type Point struct {
 	Alias       string    `json:"alias,omitempty"`
 	Connection  string    `json:"connection"`
+	Backup      []*Backup `json:"backup,omitempty"`
+	FailBack    int       `json:"failback,omitempty"` // seconds to fail back to primary, and 0 is never.
+	Bond        *Bond     `json:"bond,omitempty"`
+	Select      *Select   `json:"select,omitempty"`
+	Proxy       string    `json:"proxy,omitempty"` // http://, https:// or socks5:// with user and password.
+	Path        string    `json:"path,omitempty"`  // path of websocket on http of switch.
 	Timeout     int       `json:"timeout"`
 	Username    string    `json:"username,omitempty"`
 	Network     string    `json:"network"`
@@ -32,6 +95,9 @@ type Point struct {
 	RequestAddr bool      `json:"-"`
 	SaveFile    string    `json:"-"`
 	Queue       *Queue    `json:"queue"`
+	Batch       *Batch    `json:"batch,omitempty"`
+	Compress    string    `json:"compress,omitempty"` // snappy.
+	Join        []*Join   `json:"join,omitempty"`
 	Terminal    string    `json:"-"`
 	Cert        *Cert     `json:"cert"`
 }
@@ -78,6 +144,8 @@ func NewPoint() (c *Point) {
 	flag.StringVar(&c.SaveFile, "conf", pd.SaveFile, "The configuration file")
 	flag.StringVar(&c.Crypt.Secret, "crypt:secret", pd.Crypt.Secret, "Crypt secret")
 	flag.StringVar(&c.Crypt.Algo, "crypt:algo", pd.Crypt.Algo, "Crypt algorithm")
+	flag.StringVar(&c.Proxy, "proxy", pd.Proxy, "Proxy dials to switch through")
+	flag.StringVar(&c.Compress, "compress", pd.Compress, "Compress algorithm for frames")
 	flag.StringVar(&c.PProf, "pprof", pd.PProf, "Configure file for CPU prof")
 	flag.StringVar(&c.Cert.CaFile, "cacert", pd.Cert.CaFile, "CA certificate file")
 	flag.IntVar(&c.Timeout, "timeout", pd.Timeout, "Timeout(s) for socket write/read")
@@ -91,12 +159,56 @@ func (c *Point) Id() string {
 	return c.Connection + ":" + c.Network
 }
 
+// Switches returns address of primary switch and backups ordered by weight.
+func (c *Point) Switches() []string {
+	backups := make([]*Backup, 0, len(c.Backup))
+	for _, backup := range c.Backup {
+		if backup.Connection != "" && backup.Connection != c.Connection {
+			backups = append(backups, backup)
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Weight > backups[j].Weight
+	})
+	addrs := []string{c.Connection}
+	for _, backup := range backups {
+		addrs = append(addrs, backup.Connection)
+	}
+	return addrs
+}
+
+// Capabilities returns capabilities of point by its configuration.
+func (c *Point) Capabilities() []string {
+	caps := []string{libol.CapKeepalive}
+	aead := c.Crypt != nil && libol.IsAead(c.Crypt.Algo)
+	if c.Protocol == "udp" {
+		caps = append(caps, libol.CapReliable)
+		// session roams only if datagram is authenticated by aead.
+		if aead {
+			caps = append(caps, libol.CapSession)
+		}
+	}
+	if c.Compress != "" {
+		caps = append(caps, libol.CapCompress)
+	}
+	if aead {
+		caps = append(caps, libol.CapAead)
+	}
+	return caps
+}
+
 func (c *Point) Initialize() {
 	if err := c.Load(); err != nil {
 		libol.Warn("NewPoint.load %s", err)
 	}
 	c.Default()
 	libol.SetLogger(c.Log.File, c.Log.Verbose)
+	if c.Crypt != nil {
+		if err := c.Crypt.Correct(); err != nil {
+			libol.Error("NewPoint.crypt %s", err)
+			os.Exit(1)
+		}
+	}
 }
 
 func (c *Point) Right() {
@@ -111,6 +223,9 @@ func (c *Point) Right() {
 		}
 	}
 	RightAddr(&c.Connection, 10002)
+	for _, backup := range c.Backup {
+		RightAddr(&backup.Connection, 10002)
+	}
 	if runtime.GOOS == "darwin" {
 		c.Interface.Provider = "tun"
 	}
@@ -133,6 +248,15 @@ func (c *Point) Default() {
 		c.Queue = &Queue{}
 	}
 	c.Queue.Default()
+	if c.Batch != nil {
+		c.Batch.Default()
+	}
+	if c.Bond != nil {
+		c.Bond.Default()
+	}
+	if c.Select != nil {
+		c.Select.Default()
+	}
 	//reset zero value to default
 	if c.Connection == "" {
 		c.Connection = pd.Connection
@@ -140,12 +264,94 @@ func (c *Point) Default() {
 	if c.Interface.IfMtu == 0 {
 		c.Interface.IfMtu = pd.Interface.IfMtu
 	}
+	if c.Proxy == "" {
+		c.Proxy = libol.ProxyFromEnv()
+	}
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		c.Path = "/" + c.Path
+	}
 	if c.Timeout == 0 {
 		c.Timeout = pd.Timeout
 	}
 	if c.Crypt != nil {
 		c.Crypt.Default()
 	}
+	for _, join := range c.Join {
+		if join.Password == "" {
+			join.Password = c.Password
+		}
+		if join.Interface.IfMtu == 0 {
+			join.Interface.IfMtu = c.Interface.IfMtu
+		}
+		if join.Interface.Provider == "" {
+			join.Interface.Provider = c.Interface.Provider
+		}
+	}
+}
+
+// GetPin returns nil if no fingerprint of switches pinned, and fingerprint
+// of primary is in cert and others are in backup.
+func (c *Point) GetPin() *libol.CertPin {
+	if c.Cert == nil {
+		return nil
+	}
+	pinLock.Lock()
+	defer pinLock.Unlock()
+	pins := make(map[string]string, len(c.Backup)+1)
+	if c.Cert.Fingerprint != "" {
+		pins[c.Connection] = c.Cert.Fingerprint
+	}
+	for _, backup := range c.Backup {
+		if backup.Fingerprint != "" && backup.Connection != c.Connection {
+			pins[backup.Connection] = backup.Fingerprint
+		}
+	}
+	if len(pins) == 0 && !c.Cert.Tofu {
+		return nil
+	}
+	return &libol.CertPin{
+		Pins:    pins,
+		Tofu:    c.Cert.Tofu,
+		OnFirst: c.SavePin,
+	}
+}
+
+// SavePin writes fingerprint of switch into configuration file, and keeps
+// others.
+func (c *Point) SavePin(addr, fingerprint string) error {
+	pinLock.Lock()
+	defer pinLock.Unlock()
+	data := make(map[string]interface{}, 32)
+	if err := libol.FileExist(c.SaveFile); err == nil {
+		if err := libol.UnmarshalLoad(&data, c.SaveFile); err != nil {
+			return err
+		}
+	}
+	if addr == c.Connection {
+		c.Cert.Fingerprint = fingerprint
+		cert, ok := data["cert"].(map[string]interface{})
+		if !ok {
+			cert = make(map[string]interface{}, 8)
+			data["cert"] = cert
+		}
+		cert["fingerprint"] = fingerprint
+		return libol.MarshalSave(data, c.SaveFile, true)
+	}
+	backups, _ := data["backup"].([]interface{})
+	for i, backup := range c.Backup {
+		if backup.Connection != addr {
+			continue
+		}
+		backup.Fingerprint = fingerprint
+		if i >= len(backups) {
+			break
+		}
+		if value, ok := backups[i].(map[string]interface{}); ok {
+			value["fingerprint"] = fingerprint
+			return libol.MarshalSave(data, c.SaveFile, true)
+		}
+	}
+	return libol.NewErr("backup %s notFound", addr)
 }
 
 func (c *Point) Load() error {
@@ -15,6 +15,7 @@ type Bridge struct {
 	Name     string `json:"name"`
 	IfMtu    int    `json:"mtu"`
 	Address  string `json:"address,omitempty"`
+	Address6 string `json:"address6,omitempty"`
 	Provider string `json:"provider"`
 	Stp      string `json:"stp"`
 	Delay    int    `json:"delay"`
@@ -24,6 +25,7 @@ type IpSubnet struct {
 	Start   string `json:"start"`
 	End     string `json:"end"`
 	Netmask string `json:"netmask"`
+	Prefix6 string `json:"prefix6,omitempty"` // IPv6 prefix to alloc, like fd00::/64.
 }
 
 type PrefixRoute struct {
@@ -136,12 +138,17 @@ func (n *Network) Right() {
 		n.Bridge.Stp = "on"
 	}
 	ifAddr := strings.SplitN(n.Bridge.Address, "/", 2)[0]
+	ifAddr6 := strings.SplitN(n.Bridge.Address6, "/", 2)[0]
 	for i := range n.Routes {
 		if n.Routes[i].Metric == 0 {
 			n.Routes[i].Metric = 666
 		}
 		if n.Routes[i].NextHop == "" {
-			n.Routes[i].NextHop = ifAddr
+			if libol.IsIPv6(n.Routes[i].Prefix) {
+				n.Routes[i].NextHop = ifAddr6
+			} else {
+				n.Routes[i].NextHop = ifAddr
+			}
 		}
 	}
 	if n.OpenVPN != nil {
@@ -227,25 +234,37 @@ func (p *Perf) Right() {
 	}
 }
 
+type Listener struct {
+	Protocol string   `json:"protocol"` // tcp, tls, udp, kcp, ws and wss.
+	Listen   string   `json:"listen"`
+	Path     string   `json:"path,omitempty"`   // ws mounted on path of http, and not listen.
+	Host     []string `json:"host,omitempty"`   // hosts allowed by path.
+	Origin   []string `json:"origin,omitempty"` // origins allowed by path.
+}
+
 type Switch struct {
-	Alias     string     `json:"alias"`
-	Perf      *Perf      `json:"perf,omitempty"`
-	Protocol  string     `json:"protocol"` // tcp, tls, udp, kcp, ws and wss.
-	Listen    string     `json:"listen"`
-	Timeout   int        `json:"timeout"`
-	Http      *Http      `json:"http,omitempty"`
-	Log       Log        `json:"log"`
-	Cert      *Cert      `json:"cert,omitempty"`
-	Crypt     *Crypt     `json:"crypt,omitempty"`
-	Proxy     *Proxy     `json:"proxy,omitempty"`
-	PProf     string     `json:"pprof"`
-	Network   []*Network `json:"network,omitempty"`
-	FireWall  []FlowRule `json:"firewall,omitempty"`
-	Inspect   []string   `json:"inspect"`
-	Queue     *Queue     `json:"queue"`
-	ConfDir   string     `json:"-"`
-	TokenFile string     `json:"-"`
-	SaveFile  string     `json:"-"`
+	Alias     string      `json:"alias"`
+	Perf      *Perf       `json:"perf,omitempty"`
+	Protocol  string      `json:"protocol"` // tcp, tls, udp, kcp, ws and wss.
+	Listen    string      `json:"listen"`
+	Listener  []*Listener `json:"listener,omitempty"` // more listeners.
+	Timeout   int         `json:"timeout"`
+	Keepalive *Keepalive  `json:"keepalive,omitempty"`
+	Http      *Http       `json:"http,omitempty"`
+	Log       Log         `json:"log"`
+	Cert      *Cert       `json:"cert,omitempty"`
+	Crypt     *Crypt      `json:"crypt,omitempty"`
+	Proxy     *Proxy      `json:"proxy,omitempty"`
+	PProf     string      `json:"pprof"`
+	Network   []*Network  `json:"network,omitempty"`
+	FireWall  []FlowRule  `json:"firewall,omitempty"`
+	Inspect   []string    `json:"inspect"`
+	Queue     *Queue      `json:"queue"`
+	Batch     *Batch      `json:"batch,omitempty"`
+	Compress  string      `json:"compress,omitempty"` // snappy.
+	ConfDir   string      `json:"-"`
+	TokenFile string      `json:"-"`
+	SaveFile  string      `json:"-"`
 }
 
 var sd = &Switch{
@@ -257,7 +276,7 @@ var sd = &Switch{
 	Http: &Http{
 		Listen: "0.0.0.0:10000",
 	},
-	Listen: "0.0.0.0:10002",
+	Listen: ":10002", // dual-stack for IPv4 and IPv6.
 	Perf:   &pfd,
 }
 
@@ -277,6 +296,12 @@ func (c *Switch) Initialize() {
 		libol.Error("NewSwitch.load %s", err)
 	}
 	c.Default()
+	if c.Crypt != nil {
+		if err := c.Crypt.Correct(); err != nil {
+			libol.Error("NewSwitch.crypt %s", err)
+			os.Exit(1)
+		}
+	}
 	libol.Debug("NewSwitch %v", c)
 }
 
@@ -298,6 +323,19 @@ func (c *Switch) Right() {
 			c.Protocol = "tls"
 		}
 	}
+	for _, l := range c.Listener {
+		if l.Path != "" {
+			if !strings.HasPrefix(l.Path, "/") {
+				l.Path = "/" + l.Path
+			}
+			l.Protocol = "ws" // tls is by http.
+			continue
+		}
+		RightAddr(&l.Listen, 10002)
+		if l.Protocol == "" {
+			l.Protocol = c.Protocol
+		}
+	}
 	libol.Debug("Switch.Right Proxy %v", c.Proxy)
 	if c.Proxy != nil {
 		c.Proxy.Right()
@@ -309,6 +347,57 @@ func (c *Switch) Right() {
 	}
 }
 
+// GetListeners returns all listeners, and the first is Protocol on Listen.
+func (c *Switch) GetListeners() []Listener {
+	listeners := make([]Listener, 0, len(c.Listener)+1)
+	listeners = append(listeners, Listener{
+		Protocol: c.Protocol,
+		Listen:   c.Listen,
+	})
+	for _, l := range c.Listener {
+		listeners = append(listeners, *l)
+	}
+	return listeners
+}
+
+// GetAead returns key of the first network, and keys of others are tried
+// by the first frame of a point.
+func (c *Switch) GetAead() *libol.AeadConfig {
+	if len(c.Network) == 0 {
+		return GetAead(c.Crypt, "")
+	}
+	aead := GetAead(c.Crypt, c.Network[0].Name)
+	for _, n := range c.Network[1:] {
+		if alt := GetAead(c.Crypt, n.Name); alt != nil {
+			aead.Alternate(alt)
+		}
+	}
+	return aead
+}
+
+// Capabilities returns capabilities of switch by its configuration.
+func (c *Switch) Capabilities() []string {
+	caps := []string{libol.CapKeepalive, libol.CapBond}
+	aead := c.Crypt != nil && libol.IsAead(c.Crypt.Algo)
+	for _, l := range c.GetListeners() {
+		if l.Protocol == "udp" {
+			caps = append(caps, libol.CapReliable)
+			// session roams only if datagram is authenticated by aead.
+			if aead {
+				caps = append(caps, libol.CapSession)
+			}
+			break
+		}
+	}
+	if c.Compress != "" {
+		caps = append(caps, libol.CapCompress)
+	}
+	if aead {
+		caps = append(caps, libol.CapAead)
+	}
+	return caps
+}
+
 func (c *Switch) Default() {
 	c.Right()
 	if c.Network == nil {
@@ -317,6 +406,10 @@ func (c *Switch) Default() {
 	if c.Timeout == 0 {
 		c.Timeout = sd.Timeout
 	}
+	if c.Keepalive == nil {
+		c.Keepalive = &Keepalive{}
+	}
+	c.Keepalive.Default()
 	if c.Crypt != nil {
 		c.Crypt.Default()
 	}
@@ -324,6 +417,9 @@ func (c *Switch) Default() {
 		c.Queue = &Queue{}
 	}
 	c.Queue.Default()
+	if c.Batch != nil {
+		c.Batch.Default()
+	}
 	files, err := filepath.Glob(c.ConfDir + "/network/*.json")
 	if err != nil {
 		libol.Error("Switch.Default %s", err)
@@ -0,0 +1,91 @@
+package libol
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// Fingerprint returns SHA-256 of public key in certificate,
+// and formats it as hex string separated by colon.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	str := hex.EncodeToString(sum[:])
+	items := make([]string, 0, len(sum))
+	for i := 0; i < len(str); i += 2 {
+		items = append(items, str[i:i+2])
+	}
+	return strings.Join(items, ":")
+}
+
+func rightFingerprint(value string) string {
+	value = strings.ToLower(value)
+	value = strings.TrimPrefix(value, "sha256:")
+	return strings.Replace(value, ":", "", -1)
+}
+
+// CertPin verifies certificate of server by fingerprint of public key,
+// and trusts certificate on first use if fingerprint of it is empty.
+type CertPin struct {
+	lock    sync.Mutex
+	Pins    map[string]string // fingerprint by address of server.
+	Tofu    bool
+	OnFirst func(addr, fingerprint string) error // save fingerprint on first use.
+}
+
+func (p *CertPin) Verify(addr string, rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		return NewErr("certificate notFound")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	fp := Fingerprint(cert)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	pinned := p.Pins[addr]
+	if pinned == "" {
+		if !p.Tofu {
+			return NewErr("fingerprint of %s not configured, and %s received", addr, fp)
+		}
+		Info("CertPin.Verify: trust %s of %s on first use", fp, addr)
+		if p.OnFirst != nil {
+			if err := p.OnFirst(addr, fp); err != nil {
+				Warn("CertPin.Verify: %s", err)
+			}
+		}
+		if p.Pins == nil {
+			p.Pins = make(map[string]string, 4)
+		}
+		p.Pins[addr] = fp
+		return nil
+	}
+	if rightFingerprint(pinned) != rightFingerprint(fp) {
+		Error("CertPin.Verify: %s expected %s, but %s received", addr, pinned, fp)
+		return NewErr("fingerprint mismatched %s", fp)
+	}
+	return nil
+}
+
+// Has returns true if fingerprint of address pinned.
+func (p *CertPin) Has(addr string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.Pins[addr] != ""
+}
+
+// Update uses fingerprint of address instead of CA to verify certificate
+// of server, and keeps CA for address not pinned if not trust on first use.
+func (p *CertPin) Update(cfg *tls.Config, addr string) {
+	if !p.Tofu && !p.Has(addr) {
+		return
+	}
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return p.Verify(addr, rawCerts)
+	}
+}
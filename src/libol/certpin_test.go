@@ -0,0 +1,136 @@
+package libol
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err, "be nil.")
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "openlan"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err, "be nil.")
+	return raw
+}
+
+func TestCertPin_Verify(t *testing.T) {
+	raw := newTestCert(t)
+	cert, _ := x509.ParseCertificate(raw)
+	fp := Fingerprint(cert)
+
+	pin := &CertPin{Pins: map[string]string{"a:10002": strings.ToUpper(fp)}}
+	assert.Nil(t, pin.Verify("a:10002", [][]byte{raw}), "be nil.")
+	other := newTestCert(t)
+	assert.NotNil(t, pin.Verify("a:10002", [][]byte{other}), "mismatched.")
+	assert.NotNil(t, pin.Verify("b:10002", [][]byte{raw}), "not configured.")
+
+	saved := make(map[string]string, 2)
+	pin = &CertPin{
+		Tofu: true,
+		OnFirst: func(addr, value string) error {
+			saved[addr] = value
+			return nil
+		},
+	}
+	assert.Nil(t, pin.Verify("a:10002", [][]byte{raw}), "be nil.")
+	assert.Equal(t, fp, saved["a:10002"], "be the same.")
+	assert.NotNil(t, pin.Verify("a:10002", [][]byte{other}), "pinned.")
+	// other switch has its own pin.
+	assert.Nil(t, pin.Verify("b:10002", [][]byte{other}), "be nil.")
+	otherCert, _ := x509.ParseCertificate(other)
+	assert.Equal(t, Fingerprint(otherCert), saved["b:10002"], "be the same.")
+	assert.NotNil(t, pin.Verify("b:10002", [][]byte{raw}), "pinned.")
+}
+
+// listenTls returns address of TLS server by certificate signed by CA.
+func listenTls(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (net.Listener, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err, "be nil.")
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "switch"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	assert.Nil(t, err, "be nil.")
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{raw}, PrivateKey: key}},
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+		}
+	}()
+	return ln, raw
+}
+
+func TestCertPin_Unpinned(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	raw, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &caKey.PublicKey, caKey)
+	ca, _ := x509.ParseCertificate(raw)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	pinned, pinnedRaw := listenTls(t, ca, caKey)
+	defer pinned.Close()
+	backup, _ := listenTls(t, ca, caKey)
+	defer backup.Close()
+	cert, _ := x509.ParseCertificate(pinnedRaw)
+	pin := &CertPin{Pins: map[string]string{pinned.Addr().String(): Fingerprint(cert)}}
+
+	client := NewTcpClient(pinned.Addr().String(), &TcpConfig{
+		Tls: &tls.Config{RootCAs: pool},
+		Pin: pin,
+	})
+	assert.Nil(t, client.Connect(), "be nil.")
+	client.Close()
+	// backup not pinned is verified by CA.
+	client.SetAddress(backup.Addr().String())
+	assert.Nil(t, client.Connect(), "be nil.")
+	client.Close()
+	// but not trusted without CA.
+	client = NewTcpClient(backup.Addr().String(), &TcpConfig{
+		Tls: &tls.Config{},
+		Pin: pin,
+	})
+	assert.NotNil(t, client.Connect(), "not nil.")
+	// and pinned one mismatched is refused.
+	pin.Pins[pinned.Addr().String()] = Fingerprint(ca)
+	client.SetAddress(pinned.Addr().String())
+	assert.NotNil(t, client.Connect(), "not nil.")
+}
@@ -0,0 +1,122 @@
+package libol
+
+import (
+	"github.com/golang/snappy"
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	HlCompress = 0x8000 // flag in length of header if payload compressed.
+	HlLenMask  = 0x7fff
+)
+
+// Compressor compresses payload of ethernet frame, and is negotiated
+// by login of point.
+type Compressor interface {
+	Name() string
+	// Encode returns nil if dst is too small or compressed not smaller.
+	Encode(dst, src []byte) []byte
+	Decode(dst, src []byte) ([]byte, error)
+}
+
+type snappyCompressor struct{}
+
+func (c *snappyCompressor) Name() string {
+	return "snappy"
+}
+
+func (c *snappyCompressor) Encode(dst, src []byte) []byte {
+	if snappy.MaxEncodedLen(len(src)) > len(dst) {
+		return nil
+	}
+	buf := snappy.Encode(dst, src)
+	if len(buf) >= len(src) {
+		return nil
+	}
+	return buf
+}
+
+func (c *snappyCompressor) Decode(dst, src []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	if n > len(dst) {
+		return nil, NewErr("too large decoded %d", n)
+	}
+	return snappy.Decode(dst, src)
+}
+
+var compressors = map[string]Compressor{
+	"snappy": &snappyCompressor{},
+}
+
+// GetCompressor returns nil if name not supported.
+func GetCompressor(name string) Compressor {
+	return compressors[name]
+}
+
+func Compressors() []string {
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// compressFrame returns frame with payload compressed, or nil if the
+// frame is control or not compressible.
+func compressFrame(c Compressor, frame *FrameMessage) *FrameMessage {
+	if c == nil || frame.control || isControl(frame.frame[:frame.size]) {
+		return nil
+	}
+	zip := NewFrameMessage()
+	buf := c.Encode(zip.frame, frame.frame[:frame.size])
+	if buf == nil {
+		zip.Free()
+		return nil
+	}
+	zip.size = len(buf)
+	zip.channel = frame.channel
+	return zip
+}
+
+// decompress returns frame with payload decompressed.
+func decompress(c Compressor, data []byte) (*FrameMessage, error) {
+	if c == nil {
+		return nil, NewErr("compressor notFound")
+	}
+	frame := NewFrameMessage()
+	buf, err := c.Decode(frame.frame, data)
+	if err != nil {
+		frame.Free()
+		return nil, err
+	}
+	frame.size = len(buf)
+	frame.buffer = frame.buffer[:HlSize+frame.size]
+	frame.frame = frame.buffer[HlSize:]
+	frame.total = frame.size
+	return frame, nil
+}
+
+type compressBox struct {
+	c Compressor
+}
+
+// compressValue holds compressor changed by login while sending.
+type compressValue struct {
+	value atomic.Value
+}
+
+func (v *compressValue) Store(c Compressor) {
+	v.value.Store(compressBox{c: c})
+}
+
+func (v *compressValue) Load() Compressor {
+	if b, ok := v.value.Load().(compressBox); ok {
+		return b.c
+	}
+	return nil
+}
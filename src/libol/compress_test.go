@@ -0,0 +1,53 @@
+package libol
+
+import (
+	"crypto/rand"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestStreamMessager_Compress(t *testing.T) {
+	c, s := net.Pipe()
+	sender := &StreamMessagerImpl{}
+	receiver := &StreamMessagerImpl{}
+	sender.SetCompress(GetCompressor("snappy"))
+	receiver.SetCompress(GetCompressor("snappy"))
+	zero := make([]byte, 1500)
+	noise := make([]byte, 1500)
+	_, _ = rand.Read(noise)
+	go func() {
+		for _, data := range [][]byte{zero, noise} {
+			frame := NewFrameMessage()
+			frame.Append(data)
+			_, _ = sender.Send(c, frame)
+			frame.Free()
+		}
+		_, _ = sender.Send(c, NewControlFrame(PingReq, []byte("hi")))
+	}()
+	for _, data := range [][]byte{zero, noise} {
+		frame, err := receiver.Receive(s, MaxBuf, HlSize)
+		assert.Equal(t, nil, err, "be the same.")
+		assert.Equal(t, data, frame.Frame()[:frame.Size()], "be the same.")
+		frame.Free()
+	}
+	frame, err := receiver.Receive(s, MaxBuf, HlSize)
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, true, frame.Decode(), "be the same.")
+	assert.Equal(t, PingReq, frame.Action(), "be the same.")
+	_ = c.Close()
+}
+
+func TestCompressor_Encode(t *testing.T) {
+	c := GetCompressor("snappy")
+	data := make([]byte, 1500)
+	buf := c.Encode(make([]byte, MaxBuf), data)
+	assert.Equal(t, true, len(buf) < len(data), "be the same.")
+	plain, err := c.Decode(make([]byte, MaxBuf), buf)
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, data, plain, "be the same.")
+	// not compressed if dst is too small.
+	assert.Equal(t, 0, len(c.Encode(make([]byte, 16), data)), "be the same.")
+	_, err = c.Decode(make([]byte, 16), buf)
+	assert.NotEqual(t, nil, err, "be the same.")
+}
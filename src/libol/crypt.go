@@ -0,0 +1,343 @@
+package libol
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	AeadSalt    = 4
+	AeadNonce   = 12                     // salt(4) + sequence(8)
+	AeadNonceX  = 24                     // salt(4) + sequence(8) + random(12)
+	ReplayWords = 16                     // words of replay bitmap.
+	ReplaySize  = (ReplayWords - 1) * 64 // frames could be reordered.
+)
+
+const (
+	AlgoAes128Gcm = "aes-128-gcm"
+	AlgoAes256Gcm = "aes-256-gcm"
+	AlgoChaCha20  = "chacha20-poly1305"
+	BaseInfo      = "openlan base"
+)
+
+func IsAead(algo string) bool {
+	switch algo {
+	case AlgoAes128Gcm, AlgoAes256Gcm, AlgoChaCha20:
+		return true
+	}
+	return false
+}
+
+func AeadKeySize(algo string) int {
+	switch algo {
+	case AlgoAes128Gcm:
+		return 16
+	case AlgoAes256Gcm, AlgoChaCha20:
+		return 32
+	}
+	return 0
+}
+
+func NewAead(algo string, key []byte) (cipher.AEAD, error) {
+	if len(key) != AeadKeySize(algo) {
+		return nil, NewErr("%s: wrong key size %d", algo, len(key))
+	}
+	switch algo {
+	case AlgoAes128Gcm, AlgoAes256Gcm:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AlgoChaCha20:
+		return chacha20poly1305.New(key)
+	}
+	return nil, NewErr("%s not support", algo)
+}
+
+// NewBaseAead returns XChaCha20-Poly1305 derived from pre-shared key, and
+// random nonce of it never repeats across connections.
+func NewBaseAead(key []byte) (cipher.AEAD, error) {
+	base := make([]byte, chacha20poly1305.KeySize)
+	reader := hkdf.New(sha256.New, key, nil, []byte(BaseInfo))
+	if _, err := io.ReadFull(reader, base); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(base)
+}
+
+// AeadConfig is shared by all connections of a server or client,
+// and every connection has its own AeadCrypt by NewCrypt.
+type AeadConfig struct {
+	Algo  string
+	Rekey time.Duration // interval to exchange keys again.
+	key   []byte        // pre-shared key.
+	aead  cipher.AEAD   // seals frames before keys exchanged.
+	alts  []*AeadConfig // keys of other networks.
+}
+
+func NewAeadConfig(algo string, key []byte) (*AeadConfig, error) {
+	if _, err := NewAead(algo, key); err != nil {
+		return nil, err
+	}
+	aead, err := NewBaseAead(key)
+	if err != nil {
+		return nil, err
+	}
+	return &AeadConfig{Algo: algo, key: key, aead: aead}, nil
+}
+
+// Alternate adds key of another network, and the first frame of a connection
+// not opened by pre-shared key is tried by others.
+func (c *AeadConfig) Alternate(alt *AeadConfig) {
+	c.alts = append(c.alts, alt)
+}
+
+func (c *AeadConfig) NewCrypt() *AeadCrypt {
+	if c == nil {
+		return nil
+	}
+	a := NewAeadCrypt(c.aead)
+	a.cfg = c
+	return a
+}
+
+// ReplayWindow is a sliding window of received sequences,
+// and refer to RFC 6479.
+type ReplayWindow struct {
+	last   uint64
+	bitmap [ReplayWords]uint64
+}
+
+func (w *ReplayWindow) Reset() {
+	w.last = 0
+	for i := range w.bitmap {
+		w.bitmap[i] = 0
+	}
+}
+
+// Check returns true and mark it if seq never received.
+func (w *ReplayWindow) Check(seq uint64) bool {
+	if seq == 0 {
+		return false
+	}
+	if seq > w.last {
+		cur := w.last / 64
+		diff := seq/64 - cur
+		if diff > ReplayWords {
+			diff = ReplayWords
+		}
+		for i := uint64(1); i <= diff; i++ {
+			w.bitmap[(cur+i)%ReplayWords] = 0
+		}
+		w.last = seq
+	} else if w.last-seq >= ReplaySize {
+		return false
+	}
+	index := (seq / 64) % ReplayWords
+	bit := uint64(1) << (seq % 64)
+	if w.bitmap[index]&bit != 0 {
+		return false
+	}
+	w.bitmap[index] |= bit
+	return true
+}
+
+type aeadPeer struct {
+	aead   cipher.AEAD
+	salt   []byte // salt of peer, locked by first frame.
+	window ReplayWindow
+}
+
+func (p *aeadPeer) match(salt []byte) bool {
+	return p.salt != nil && bytes.Equal(p.salt, salt)
+}
+
+// AeadCrypt seals a frame as MAGIC(2)|Length(2)|Salt(4)|Sequence(8)|Data|Tag,
+// and the header of frame is authenticated as additional data. Frames sealed
+// by pre-shared key have Random(12) after Sequence.
+type AeadCrypt struct {
+	lock  sync.Mutex
+	cfg   *AeadConfig
+	base  cipher.AEAD // pre-shared key before exchanged.
+	seal  cipher.AEAD
+	next  cipher.AEAD // seal by it after peer confirmed.
+	salt  []byte
+	seq   uint64
+	open  *aeadPeer
+	prev  *aeadPeer // keys before exchanged, and still accept it.
+	since time.Time
+}
+
+func NewAeadCrypt(aead cipher.AEAD) *AeadCrypt {
+	a := &AeadCrypt{base: aead}
+	a.reset()
+	return a
+}
+
+func (a *AeadCrypt) Overhead() int {
+	return AeadNonceX + a.base.Overhead()
+}
+
+func (a *AeadCrypt) newSalt() {
+	a.salt = make([]byte, AeadSalt)
+	if _, err := rand.Read(a.salt); err != nil {
+		copy(a.salt, GenEthAddr(AeadSalt))
+	}
+}
+
+func (a *AeadCrypt) reset() {
+	a.seal = a.base
+	a.next = nil
+	a.open = &aeadPeer{aead: a.base}
+	a.prev = nil
+	a.since = time.Now()
+	a.newSalt()
+}
+
+// Reset falls back to pre-shared key, and called when connection changed.
+func (a *AeadCrypt) Reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.reset()
+}
+
+// Update installs keys exchanged, if confirm is true, sealing by new key
+// until the first frame from peer is opened by it.
+func (a *AeadCrypt) Update(seal, open cipher.AEAD, confirm bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.prev == nil || a.open.salt != nil {
+		a.prev = a.open
+	}
+	a.open = &aeadPeer{aead: open}
+	if confirm {
+		a.next = seal
+	} else {
+		a.seal = seal
+		a.next = nil
+		a.newSalt()
+	}
+	a.since = time.Now()
+}
+
+// Config returns config of pre-shared key, which is changed by the first
+// frame opened by key of another network.
+func (a *AeadCrypt) Config() *AeadConfig {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.cfg
+}
+
+// Since returns the time of keys updated.
+func (a *AeadCrypt) Since() time.Time {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.since
+}
+
+// Seal returns a new buffer included header and sequence used.
+func (a *AeadCrypt) Seal(data []byte) ([]byte, uint64) {
+	return a.SealFlag(data, 0, 0)
+}
+
+// SealFlag seals data with channel and flag in header, which is
+// authenticated as additional data.
+func (a *AeadCrypt) SealFlag(data []byte, channel uint8, flag uint16) ([]byte, uint64) {
+	a.lock.Lock()
+	aead, salt := a.seal, a.salt
+	a.lock.Unlock()
+	ns := aead.NonceSize()
+	size := ns + len(data) + aead.Overhead()
+	buf := make([]byte, HlSize+ns, HlSize+size)
+	putHeader(buf, channel, uint16(size)|flag)
+	seq := atomic.AddUint64(&a.seq, 1)
+	nonce := buf[HlSize : HlSize+ns]
+	copy(nonce[:AeadSalt], salt)
+	binary.BigEndian.PutUint64(nonce[AeadSalt:AeadNonce], seq)
+	if ns > AeadNonce {
+		_, _ = rand.Read(nonce[AeadNonce:])
+	}
+	return aead.Seal(buf, nonce, data, buf[:HlSize]), seq
+}
+
+// Open decrypts a buffer included header in place, and returns plain text.
+func (a *AeadCrypt) Open(buf []byte) ([]byte, uint64, error) {
+	if len(buf) < HlSize+AeadNonce {
+		return nil, 0, NewErr("too small frame %d", len(buf))
+	}
+	salt := buf[HlSize : HlSize+AeadSalt]
+	seq := binary.BigEndian.Uint64(buf[HlSize+AeadSalt : HlSize+AeadNonce])
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	peer := a.open
+	if a.prev != nil && a.prev.match(salt) {
+		peer = a.prev
+	} else if peer.salt != nil && !peer.match(salt) {
+		return nil, seq, NewErr("wrong salt %x", salt)
+	}
+	ns := peer.aead.NonceSize()
+	if len(buf) < HlSize+ns+peer.aead.Overhead() {
+		return nil, seq, NewErr("too small frame %d", len(buf))
+	}
+	nonce := buf[HlSize : HlSize+ns]
+	data := buf[HlSize+ns:]
+	var orig []byte
+	first := peer == a.open && peer.salt == nil && peer.aead == a.base
+	if first && a.cfg != nil && len(a.cfg.alts) > 0 {
+		orig = append([]byte{}, data...) // overwritten if failed.
+	}
+	plain, err := peer.aead.Open(data[:0], nonce, data, buf[:HlSize])
+	if err != nil && orig != nil {
+		plain, err = a.alternate(peer, orig, nonce, data, buf[:HlSize])
+	}
+	if err != nil {
+		return nil, seq, NewErr("authenticate failed")
+	}
+	if !peer.window.Check(seq) {
+		return nil, seq, NewErr("replayed sequence %d", seq)
+	}
+	if peer.salt == nil {
+		peer.salt = make([]byte, AeadSalt)
+		copy(peer.salt, salt)
+	}
+	if peer == a.open && a.next != nil { // confirmed by peer.
+		a.seal = a.next
+		a.next = nil
+		a.newSalt()
+	}
+	return plain, seq, nil
+}
+
+// alternate opens the first frame by keys of other networks, and uses the
+// key authenticated it from now on.
+func (a *AeadCrypt) alternate(peer *aeadPeer, orig, nonce, data, head []byte) ([]byte, error) {
+	for _, alt := range a.cfg.alts {
+		copy(data, orig)
+		plain, err := alt.aead.Open(data[:0], nonce, data, head)
+		if err != nil {
+			continue
+		}
+		a.cfg, a.base, a.seal = alt, alt.aead, alt.aead
+		peer.aead = alt.aead
+		return plain, nil
+	}
+	return nil, NewErr("authenticate failed")
+}
+
+// headOf returns plain text opened in place of buf with room of header in
+// front, and the nonce before plain text is variable.
+func headOf(buf, plain []byte) []byte {
+	start := cap(buf) - cap(plain)
+	return buf[start-HlSize : start+len(plain)]
+}
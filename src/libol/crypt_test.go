@@ -0,0 +1,82 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAeadCrypt_SealOpen(t *testing.T) {
+	for _, algo := range []string{AlgoAes128Gcm, AlgoAes256Gcm, AlgoChaCha20} {
+		cfg, err := NewAeadConfig(algo, make([]byte, AeadKeySize(algo)))
+		assert.Nil(t, err, "be nil.")
+		a := cfg.NewCrypt()
+		b := cfg.NewCrypt()
+
+		data := []byte("hello openlan")
+		buf, seq := a.Seal(data)
+		assert.Equal(t, uint64(1), seq, "be the same.")
+		assert.Equal(t, HlSize+AeadNonceX+len(data)+16, len(buf), "be the same.")
+		replay := make([]byte, len(buf))
+		copy(replay, buf)
+		plain, _, err := b.Open(buf)
+		assert.Nil(t, err, "be nil.")
+		assert.Equal(t, data, plain, "be the same.")
+		_, _, err = b.Open(replay)
+		assert.NotNil(t, err, "replayed.")
+
+		buf, _ = a.Seal(data)
+		buf[len(buf)-1] ^= 0x01
+		_, _, err = b.Open(buf)
+		assert.NotNil(t, err, "tampered.")
+	}
+}
+
+func TestAeadCrypt_Alternate(t *testing.T) {
+	keys := make([]*AeadConfig, 0, 3)
+	for i := 0; i < 3; i++ {
+		key := make([]byte, AeadKeySize(AlgoChaCha20))
+		key[0] = byte(i)
+		cfg, err := NewAeadConfig(AlgoChaCha20, key)
+		assert.Nil(t, err, "be nil.")
+		keys = append(keys, cfg)
+	}
+	server, _ := NewAeadConfig(AlgoChaCha20, keys[0].key)
+	server.Alternate(keys[1])
+	data := []byte("hello openlan")
+
+	// opened by key of another network, and sealed by it then.
+	a := keys[1].NewCrypt()
+	b := server.NewCrypt()
+	buf, _ := a.Seal(data)
+	plain, _, err := b.Open(buf)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, data, plain, "be the same.")
+	assert.Equal(t, keys[1], b.Config(), "be the same.")
+	buf, _ = b.Seal(data)
+	plain, _, err = a.Open(buf)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, data, plain, "be the same.")
+	buf, _ = keys[0].NewCrypt().Seal(data)
+	_, _, err = b.Open(buf)
+	assert.NotNil(t, err, "key locked.")
+
+	// key not known.
+	b = server.NewCrypt()
+	buf, _ = keys[2].NewCrypt().Seal(data)
+	_, _, err = b.Open(buf)
+	assert.NotNil(t, err, "not known.")
+	assert.Equal(t, server, b.Config(), "be the same.")
+}
+
+func TestReplayWindow_Check(t *testing.T) {
+	w := ReplayWindow{}
+	assert.Equal(t, false, w.Check(0), "be the same.")
+	assert.Equal(t, true, w.Check(2), "be the same.")
+	assert.Equal(t, true, w.Check(1), "be the same.")
+	assert.Equal(t, false, w.Check(1), "be the same.")
+	assert.Equal(t, true, w.Check(ReplaySize+10), "be the same.")
+	assert.Equal(t, false, w.Check(2), "be the same.")
+	assert.Equal(t, true, w.Check(11), "be the same.")
+	w.Reset()
+	assert.Equal(t, true, w.Check(2), "be the same.")
+}
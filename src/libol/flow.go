@@ -0,0 +1,50 @@
+package libol
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+)
+
+// FlowHash returns hash of flow which ethernet frame belongs to, and frames
+// of one flow have same hash so they are never reordered across paths.
+func FlowHash(frame []byte) uint32 {
+	h := fnv.New32a()
+	if len(frame) < EtherLen {
+		_, _ = h.Write(frame)
+		return h.Sum32()
+	}
+	data := frame[EtherLen:]
+	switch binary.BigEndian.Uint16(frame[12:14]) {
+	case EthIp4:
+		if len(data) < Ipv4Len {
+			break
+		}
+		proto := data[9]
+		_, _ = h.Write(data[9:10])
+		_, _ = h.Write(data[12:20]) // source and destination.
+		hl := int(data[0]&0x0f) * 4
+		frag := binary.BigEndian.Uint16(data[6:8]) & 0x3fff
+		if frag == 0 && hl >= Ipv4Len {
+			writePorts(h, proto, data[hl:])
+		}
+		return h.Sum32()
+	case EthIp6:
+		if len(data) < Ipv6Len {
+			break
+		}
+		proto := data[6]
+		_, _ = h.Write(data[6:7])
+		_, _ = h.Write(data[8:40]) // source and destination.
+		writePorts(h, proto, data[Ipv6Len:])
+		return h.Sum32()
+	}
+	_, _ = h.Write(frame[:14]) // addresses and type of ethernet.
+	return h.Sum32()
+}
+
+func writePorts(h hash.Hash32, proto uint8, data []byte) {
+	if (proto == IpTcp || proto == IpUdp) && len(data) >= 4 {
+		_, _ = h.Write(data[:4])
+	}
+}
@@ -0,0 +1,31 @@
+package libol
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newUdp(src, dst byte, sport, dport uint16) []byte {
+	frame := make([]byte, EtherLen+Ipv4Len+8)
+	binary.BigEndian.PutUint16(frame[12:14], EthIp4)
+	ip := frame[EtherLen:]
+	ip[0] = 0x45
+	ip[9] = IpUdp
+	ip[15] = src
+	ip[19] = dst
+	binary.BigEndian.PutUint16(ip[Ipv4Len:], sport)
+	binary.BigEndian.PutUint16(ip[Ipv4Len+2:], dport)
+	return frame
+}
+
+func TestFlowHash(t *testing.T) {
+	a := newUdp(1, 2, 1000, 53)
+	b := newUdp(1, 2, 1000, 53)
+	copy(b[EtherLen+Ipv4Len+8-1:], []byte{0xff}) // payload not in flow.
+	assert.Equal(t, FlowHash(a), FlowHash(b), "be the same.")
+	c := newUdp(1, 2, 1001, 53)
+	assert.NotEqual(t, FlowHash(a), FlowHash(c), "not same.")
+	d := newUdp(1, 3, 1000, 53)
+	assert.NotEqual(t, FlowHash(a), FlowHash(d), "not same.")
+}
@@ -0,0 +1,225 @@
+package libol
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	HandTimeout = 10 * time.Second // wait for response of handshake.
+	HandInfo    = "openlan handshake"
+)
+
+type HandMessage struct {
+	Public string `json:"public"`
+	Peer   string `json:"peer,omitempty"` // public key of requester.
+}
+
+// Handshake exchanges ephemeral X25519 keys before login, and derives keys
+// of session by HKDF with pre-shared key. The client requests it again when
+// keys expired, and previous keys still accepted until peer updated.
+type Handshake struct {
+	lock    sync.Mutex
+	crypt   *AeadCrypt
+	client  bool
+	private []byte
+	public  []byte
+	pending time.Time // time of request sent.
+	done    bool
+}
+
+func NewHandshake(crypt *AeadCrypt, client bool) *Handshake {
+	if crypt == nil || crypt.cfg == nil {
+		return nil
+	}
+	return &Handshake{
+		crypt:  crypt,
+		client: client,
+	}
+}
+
+func (h *Handshake) Reset() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.private = nil
+	h.public = nil
+	h.pending = time.Time{}
+	h.done = false
+}
+
+func (h *Handshake) Done() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.done
+}
+
+func (h *Handshake) expired() bool {
+	rekey := h.crypt.Config().Rekey
+	if !h.client || !h.done || rekey == 0 {
+		return false
+	}
+	if !h.pending.IsZero() && time.Since(h.pending) < HandTimeout {
+		return false
+	}
+	return time.Since(h.crypt.Since()) > rekey
+}
+
+func (h *Handshake) newKey() error {
+	h.private = make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(h.private); err != nil {
+		return err
+	}
+	public, err := curve25519.X25519(h.private, curve25519.Basepoint)
+	if err != nil {
+		return err
+	}
+	h.public = public
+	return nil
+}
+
+// derive returns keys of sending and receiving.
+func (h *Handshake) derive(peer, client, server []byte) ([]byte, []byte, error) {
+	shared, err := curve25519.X25519(h.private, peer)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := h.crypt.Config()
+	info := append([]byte(HandInfo), client...)
+	info = append(info, server...)
+	reader := hkdf.New(sha256.New, shared, cfg.key, info)
+	size := AeadKeySize(cfg.Algo)
+	toServer := make([]byte, size)
+	toClient := make([]byte, size)
+	if _, err := io.ReadFull(reader, toServer); err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.ReadFull(reader, toClient); err != nil {
+		return nil, nil, err
+	}
+	if h.client {
+		return toServer, toClient, nil
+	}
+	return toClient, toServer, nil
+}
+
+func (h *Handshake) update(seal, open []byte, confirm bool) error {
+	algo := h.crypt.Config().Algo
+	sealer, err := NewAead(algo, seal)
+	if err != nil {
+		return err
+	}
+	opener, err := NewAead(algo, open)
+	if err != nil {
+		return err
+	}
+	h.crypt.Update(sealer, opener, confirm)
+	return nil
+}
+
+func (h *Handshake) request() (*FrameMessage, error) {
+	if err := h.newKey(); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(&HandMessage{
+		Public: base64.StdEncoding.EncodeToString(h.public),
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.pending = time.Now()
+	return NewControlFrame(HandReq, body), nil
+}
+
+// Request returns a new request of client.
+func (h *Handshake) Request() (*FrameMessage, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.request()
+}
+
+// Rekey returns a new request if keys expired, otherwise returns nil.
+func (h *Handshake) Rekey() (*FrameMessage, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if !h.expired() {
+		return nil, nil
+	}
+	return h.request()
+}
+
+// OnRequest returns response of server, and the call to update keys
+// must be executed after response sent.
+func (h *Handshake) OnRequest(data []byte) (*FrameMessage, func() error, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	req := &HandMessage{}
+	if err := json.Unmarshal(data, req); err != nil {
+		return nil, nil, NewErr("invalid handshake")
+	}
+	peer, err := base64.StdEncoding.DecodeString(req.Public)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := h.newKey(); err != nil {
+		return nil, nil, err
+	}
+	seal, open, err := h.derive(peer, peer, h.public)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := json.Marshal(&HandMessage{
+		Public: base64.StdEncoding.EncodeToString(h.public),
+		Peer:   req.Public,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	h.private = nil
+	h.done = true
+	call := func() error {
+		return h.update(seal, open, true)
+	}
+	return NewControlFrame(HandResp, body), call, nil
+}
+
+// OnResponse updates keys of client by response of server.
+func (h *Handshake) OnResponse(data []byte) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	resp := &HandMessage{}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return NewErr("invalid handshake")
+	}
+	if h.private == nil {
+		return NewErr("handshake not requested")
+	}
+	if resp.Peer != base64.StdEncoding.EncodeToString(h.public) {
+		return NewErr("handshake not matched")
+	}
+	peer, err := base64.StdEncoding.DecodeString(resp.Public)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(peer, h.public) {
+		return NewErr("handshake with itself")
+	}
+	seal, open, err := h.derive(peer, h.public, peer)
+	if err != nil {
+		return err
+	}
+	if err := h.update(seal, open, false); err != nil {
+		return err
+	}
+	h.private = nil
+	h.pending = time.Time{}
+	h.done = true
+	return nil
+}
@@ -0,0 +1,57 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandshake_TcpClient(t *testing.T) {
+	cfg, err := NewAeadConfig(AlgoChaCha20, make([]byte, 32))
+	assert.Nil(t, err, "be nil.")
+	tcpCfg := &TcpConfig{Aead: cfg}
+	c, s := net.Pipe()
+	client := NewTcpClient("pipe", tcpCfg)
+	client.SetConnection(c)
+	server := NewTcpClientFromConn(s, tcpCfg)
+
+	frames := make(chan *FrameMessage, 8)
+	go func() {
+		for {
+			frame, err := server.ReadMsg()
+			if err != nil {
+				close(frames)
+				return
+			}
+			frames <- frame
+		}
+	}()
+	assert.Nil(t, client.handshake(), "be nil.")
+	assert.Equal(t, true, server.hand.Done(), "be the same.")
+
+	replies := make(chan *FrameMessage, 8)
+	go func() {
+		for {
+			frame, err := client.ReadMsg()
+			if err != nil {
+				close(replies)
+				return
+			}
+			replies <- frame
+		}
+	}()
+	// rekey after keys expired, and frames still passed.
+	cfg.Rekey = time.Nanosecond
+	for i := 0; i < 3; i++ {
+		data := []byte("hello server")
+		frame := NewFrameMessageFromBytes(append(make([]byte, HlSize), data...))
+		assert.Nil(t, client.WriteMsg(frame), "be nil.")
+		assert.Equal(t, data, (<-frames).Frame(), "be the same.")
+		data = []byte("hello client")
+		frame = NewFrameMessageFromBytes(append(make([]byte, HlSize), data...))
+		assert.Nil(t, server.WriteMsg(frame), "be nil.")
+		assert.Equal(t, data, (<-replies).Frame(), "be the same.")
+	}
+	_ = c.Close()
+}
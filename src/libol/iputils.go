@@ -1,11 +1,33 @@
 package libol
 
 import (
+	"net"
 	"os/exec"
 	"runtime"
 	"strings"
 )
 
+// IsIPv6 returns true if address or prefix is IPv6.
+func IsIPv6(addr string) bool {
+	ip := net.ParseIP(strings.SplitN(addr, "/", 2)[0])
+	return ip != nil && ip.To4() == nil
+}
+
+func ipFamily(addr string) string {
+	if IsIPv6(addr) {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// ifName returns argument of interface for netsh.
+func ifName(addr, name string) string {
+	if IsIPv6(addr) {
+		return "interface=" + name
+	}
+	return "name=" + name
+}
+
 func IpLinkUp(name string) ([]byte, error) {
 	switch runtime.GOOS {
 	case "linux":
@@ -51,11 +73,19 @@ func IpAddrAdd(name, addr string, opts ...string) ([]byte, error) {
 		return exec.Command("/usr/sbin/ip", args...).CombinedOutput()
 	case "windows":
 		args := append([]string{
-			"interface", "ipv4", "add", "address",
-			"name=" + name, "address=" + addr, "store=active",
+			"interface", ipFamily(addr), "add", "address",
+			ifName(addr, name), "address=" + addr, "store=active",
 		}, opts...)
 		return exec.Command("netsh", args...).CombinedOutput()
 	case "darwin":
+		if IsIPv6(addr) {
+			ips := strings.SplitN(addr, "/", 2)
+			args := []string{name, "inet6", ips[0]}
+			if len(ips) == 2 {
+				args = append(args, "prefixlen", ips[1])
+			}
+			return exec.Command("/sbin/ifconfig", args...).CombinedOutput()
+		}
 		args := append([]string{
 			name, addr,
 		}, opts...)
@@ -75,11 +105,16 @@ func IpAddrDel(name, addr string) ([]byte, error) {
 	case "windows":
 		ipAddr := strings.SplitN(addr, "/", 1)[0]
 		args := []string{
-			"interface", "ipv4", "delete", "address",
-			"name=" + name, "address=" + ipAddr, "store=active",
+			"interface", ipFamily(addr), "delete", "address",
+			ifName(addr, name), "address=" + ipAddr, "store=active",
 		}
 		return exec.Command("netsh", args...).CombinedOutput()
 	case "darwin":
+		if IsIPv6(addr) {
+			ipAddr := strings.SplitN(addr, "/", 2)[0]
+			args := []string{name, "inet6", ipAddr, "delete"}
+			return exec.Command("/sbin/ifconfig", args...).CombinedOutput()
+		}
 		args := []string{
 			name, addr, "delete",
 		}
@@ -125,14 +160,17 @@ func IpRouteAdd(name, prefix, nexthop string, opts ...string) ([]byte, error) {
 		return exec.Command("/usr/sbin/ip", args...).CombinedOutput()
 	case "windows":
 		args := []string{
-			"interface", "ipv4", "add", "route",
+			"interface", ipFamily(prefix), "add", "route",
 			"prefix=" + prefix, "interface=" + name, "nexthop=" + nexthop,
 			"store=active",
 		}
 		return exec.Command("netsh", args...).CombinedOutput()
 	case "darwin":
-		args := append([]string{
-			"add", "-net", prefix})
+		args := []string{"add"}
+		if IsIPv6(prefix) {
+			args = append(args, "-inet6")
+		}
+		args = append(args, "-net", prefix)
 		if name != "" {
 			args = append(args, "-iface", name)
 		}
@@ -155,14 +193,17 @@ func IpRouteDel(name, prefix, nexthop string, opts ...string) ([]byte, error) {
 		return exec.Command("/usr/sbin/ip", args...).CombinedOutput()
 	case "windows":
 		args := []string{
-			"interface", "ipv4", "delete", "route",
+			"interface", ipFamily(prefix), "delete", "route",
 			"prefix=" + prefix, "interface=" + name, "nexthop=" + nexthop,
 			"store=active",
 		}
 		return exec.Command("netsh", args...).CombinedOutput()
 	case "darwin":
-		args := append([]string{
-			"delete", "-net", prefix})
+		args := []string{"delete"}
+		if IsIPv6(prefix) {
+			args = append(args, "-inet6")
+		}
+		args = append(args, "-net", prefix)
 		if name != "" {
 			args = append(args, "-iface", name)
 		}
@@ -8,6 +8,7 @@ import (
 
 type KcpConfig struct {
 	Block        kcp.BlockCrypt
+	Aead         *AeadConfig
 	DataShards   int           // default 1024
 	ParityShards int           // default 3
 	Timeout      time.Duration // ns
@@ -102,6 +103,7 @@ func NewKcpClient(addr string, cfg *KcpConfig) *KcpClient {
 		kcpCfg: cfg,
 		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
 			timeout: cfg.Timeout,
+			aead:    cfg.Aead.NewCrypt(),
 		}),
 	}
 	return c
@@ -115,8 +117,10 @@ func NewKcpClientFromConn(conn net.Conn, cfg *KcpConfig) *KcpClient {
 	c := &KcpClient{
 		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
 			timeout: cfg.Timeout,
+			aead:    cfg.Aead.NewCrypt(),
 		}),
 	}
+	c.hand = NewHandshake(c.message.Crypt(), false)
 	c.updateConn(conn)
 	return c
 }
@@ -125,9 +129,10 @@ func (c *KcpClient) Connect() error {
 	if !c.Retry() {
 		return nil
 	}
-	c.out.Info("KcpClient.Connect: kcp://%s", c.address)
+	addr := c.String()
+	c.out.Info("KcpClient.Connect: kcp://%s", addr)
 	conn, err := kcp.DialWithOptions(
-		c.address,
+		addr,
 		c.kcpCfg.Block,
 		c.kcpCfg.DataShards,
 		c.kcpCfg.DataShards)
@@ -138,6 +143,10 @@ func (c *KcpClient) Connect() error {
 	conn.SetWriteDelay(false)
 	conn.SetACKNoDelay(false)
 	c.SetConnection(conn)
+	if err := c.handshake(); err != nil {
+		c.Close()
+		return err
+	}
 	if c.listener.OnConnected != nil {
 		_ = c.listener.OnConnected(c)
 	}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/xtaci/kcp-go/v5"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -18,8 +19,21 @@ const (
 	EthDI    = 0x06
 )
 
+// MAGIC is followed by length of frame, and the second byte of it is
+// 0xff minus channel, so frames of channel 0 are same as before.
 var MAGIC = []byte{0xff, 0xff}
 
+// putHeader writes magic with channel, and length with flags.
+func putHeader(buf []byte, channel uint8, size uint16) {
+	buf[0] = MAGIC[0]
+	buf[1] = MAGIC[1] - channel
+	binary.BigEndian.PutUint16(buf[HlMI:HlLI], size)
+}
+
+func channelOf(buf []byte) uint8 {
+	return MAGIC[1] - buf[1]
+}
+
 const (
 	LoginReq     = "logi= "
 	LoginResp    = "logi: "
@@ -31,6 +45,22 @@ const (
 	SignReq      = "sign= "
 	PingReq      = "ping= "
 	PongResp     = "pong: "
+	HandReq      = "hand= "
+	HandResp     = "hand: "
+	AckResp      = "ackn: "
+	JoinReq      = "join= "
+	JoinResp     = "join: "
+	MtuReq       = "mtup= "
+	MtuResp      = "mtup: "
+	ProbeReq     = "prob= "
+	ProbeResp    = "prob: "
+)
+
+// Sequenced control is action and operator followed by '#', sequence of
+// sender and sequence of request replied in big endian, then params.
+const (
+	CtlSeqMark = '#'
+	CtlSeqLen  = 2*EthDI + 8
 )
 
 func isControl(data []byte) bool {
@@ -49,6 +79,9 @@ type FrameProto struct {
 	Vlan  *Vlan
 	Arp   *Arp
 	Ip4   *Ipv4
+	Ip6   *Ipv6
+	Icmp6 *Icmpv6
+	Ndp   *Ndp
 	Udp   *Udp
 	Tcp   *Tcp
 	Err   error
@@ -61,61 +94,121 @@ func (i *FrameProto) Decode() error {
 		return i.Err
 	}
 	data = data[i.Eth.Len:]
+	proto := i.Eth.Type
 	if i.Eth.IsVlan() {
 		if i.Vlan, i.Err = NewVlanFromFrame(data); i.Err != nil {
 			return i.Err
 		}
 		data = data[i.Vlan.Len:]
+		proto = i.Vlan.Pro
 	}
-	switch i.Eth.Type {
+	switch proto {
 	case EthIp4:
 		if i.Ip4, i.Err = NewIpv4FromFrame(data); i.Err != nil {
 			return i.Err
 		}
 		data = data[i.Ip4.Len:]
-		switch i.Ip4.Protocol {
-		case IpTcp:
-			if i.Tcp, i.Err = NewTcpFromFrame(data); i.Err != nil {
-				return i.Err
-			}
-		case IpUdp:
-			if i.Udp, i.Err = NewUdpFromFrame(data); i.Err != nil {
-				return i.Err
-			}
+		i.decodeL4(i.Ip4.Protocol, data)
+	case EthIp6:
+		if i.Ip6, i.Err = NewIpv6FromFrame(data); i.Err != nil {
+			return i.Err
 		}
+		data = data[i.Ip6.Len:]
+		i.decodeL4(i.Ip6.Protocol, data)
 	case EthArp:
 		if i.Arp, i.Err = NewArpFromFrame(data); i.Err != nil {
 			return i.Err
 		}
 	}
-	return nil
+	return i.Err
+}
+
+func (i *FrameProto) decodeL4(proto uint8, data []byte) {
+	switch proto {
+	case IpTcp:
+		i.Tcp, i.Err = NewTcpFromFrame(data)
+	case IpUdp:
+		i.Udp, i.Err = NewUdpFromFrame(data)
+	case IpIcmp6:
+		if i.Icmp6, i.Err = NewIcmpv6FromFrame(data); i.Err != nil {
+			return
+		}
+		if i.Icmp6.IsNeighbor() {
+			i.Ndp, i.Err = NewNdpFromFrame(data[i.Icmp6.Len:])
+		}
+	}
 }
 
+// FrameBuffer holds header and payload of a frame, and is recycled by pool.
+type FrameBuffer [HlSize + MaxBuf]byte
+
+var framePool = sync.Pool{
+	New: func() interface{} {
+		return new(FrameBuffer)
+	},
+}
+
+func GetFrameBuffer() *FrameBuffer {
+	return framePool.Get().(*FrameBuffer)
+}
+
+func PutFrameBuffer(b *FrameBuffer) {
+	if b != nil {
+		framePool.Put(b)
+	}
+}
+
+// FrameMessage is owned by the last consumer of it, who calls Free after
+// the frame written to device or socket, or dropped. A frame is not used
+// after freed, and frame not freed is collected by GC as usual.
 type FrameMessage struct {
 	seq     uint64
 	control bool
 	action  string
 	params  []byte
+	ctlSeq  uint32 // sequence of control frame.
+	ctlRef  uint32 // sequence of request replied.
+	channel uint8  // network joined, and 0 is the one logged in.
 	buffer  []byte
 	size    int
 	total   int
 	frame   []byte
 	proto   *FrameProto
+	pool    *FrameBuffer
 }
 
 func NewFrameMessage() *FrameMessage {
+	pool := GetFrameBuffer()
+	m := FrameMessage{
+		buffer: pool[:],
+		pool:   pool,
+	}
+	m.frame = m.buffer[HlSize:]
+	m.total = len(m.frame)
+	return &m
+}
+
+// NewFrameMessageFromPool copies frame with header into buffer from pool.
+func NewFrameMessageFromPool(data []byte) *FrameMessage {
+	if len(data) > HlSize+MaxBuf {
+		buffer := make([]byte, len(data))
+		copy(buffer, data)
+		return NewFrameMessageFromBytes(buffer)
+	}
+	pool := GetFrameBuffer()
+	n := copy(pool[:], data)
 	m := FrameMessage{
-		params: make([]byte, 0, 2),
-		buffer: make([]byte, HlSize+MaxBuf),
+		buffer: pool[:n],
+		pool:   pool,
 	}
 	m.frame = m.buffer[HlSize:]
 	m.total = len(m.frame)
+	m.size = len(m.frame)
 	return &m
 }
 
 func NewFrameMessageFromBytes(buffer []byte) *FrameMessage {
 	m := FrameMessage{
-		params: make([]byte, 0, 2),
 		buffer: buffer,
 	}
 	m.frame = m.buffer[HlSize:]
@@ -129,10 +222,35 @@ func (m *FrameMessage) Decode() bool {
 	if m.control {
 		m.action = string(m.frame[EthDI : 2*EthDI])
 		m.params = m.frame[2*EthDI:]
+		if m.frame[2*EthDI-1] == CtlSeqMark && len(m.frame) >= CtlSeqLen {
+			m.action = m.action[:EthDI-1] + " "
+			m.ctlSeq = binary.BigEndian.Uint32(m.frame[2*EthDI : 2*EthDI+4])
+			m.ctlRef = binary.BigEndian.Uint32(m.frame[2*EthDI+4 : CtlSeqLen])
+			m.params = m.frame[CtlSeqLen:]
+		}
 	}
 	return m.control
 }
 
+// CtlSeq returns sequence of control frame, and 0 if not sequenced.
+func (m *FrameMessage) CtlSeq() uint32 {
+	return m.ctlSeq
+}
+
+// CtlRef returns sequence of request which the response replied.
+func (m *FrameMessage) CtlRef() uint32 {
+	return m.ctlRef
+}
+
+// Channel returns id of network which the frame belongs to.
+func (m *FrameMessage) Channel() uint8 {
+	return m.channel
+}
+
+func (m *FrameMessage) SetChannel(v uint8) {
+	m.channel = v
+}
+
 func (m *FrameMessage) IsEthernet() bool {
 	return !m.control
 }
@@ -173,6 +291,20 @@ func (m *FrameMessage) SetSize(v int) {
 	m.size = v
 }
 
+// Free returns buffer to pool if it is from pool.
+func (m *FrameMessage) Free() {
+	if m.pool == nil {
+		return
+	}
+	PutFrameBuffer(m.pool)
+	m.pool = nil
+	m.buffer = nil
+	m.frame = nil
+	m.proto = nil
+	m.size = 0
+	m.total = 0
+}
+
 func (m *FrameMessage) Proto() (*FrameProto, error) {
 	if m.proto == nil {
 		m.proto = &FrameProto{Frame: m.frame}
@@ -207,6 +339,25 @@ func NewControlMessage(action, opr string, body []byte) *ControlMessage {
 	return &c
 }
 
+// NewSeqControlFrame returns a control frame with sequence, and ref is
+// sequence of request replied by it.
+func NewSeqControlFrame(action string, seq, ref uint32, body []byte) *FrameMessage {
+	frame := NewFrameMessage()
+	frame.control = true
+	frame.action = action
+	frame.ctlSeq = seq
+	frame.ctlRef = ref
+	hdr := make([]byte, CtlSeqLen)
+	copy(hdr[EthDI:], action[:EthDI-1])
+	hdr[2*EthDI-1] = CtlSeqMark
+	binary.BigEndian.PutUint32(hdr[2*EthDI:], seq)
+	binary.BigEndian.PutUint32(hdr[2*EthDI+4:], ref)
+	frame.Append(hdr)
+	frame.Append(body)
+	frame.params = frame.frame[CtlSeqLen:frame.size]
+	return frame
+}
+
 func (c *ControlMessage) Encode() *FrameMessage {
 	p := fmt.Sprintf("%s%s%s", c.action[:4], c.operator[:2], c.params)
 	frame := NewFrameMessage()
@@ -222,17 +373,51 @@ type Messager interface {
 	Send(conn net.Conn, frame *FrameMessage) (int, error)
 	Receive(conn net.Conn, max, min int) (*FrameMessage, error)
 	Flush()
+	Crypt() *AeadCrypt
+	SetCompress(c Compressor)
 }
 
 type StreamMessagerImpl struct {
 	timeout time.Duration // ns for read and write deadline.
 	block   kcp.BlockCrypt
-	buffer  []byte
-	bufSize int // default is (1518 + 20+20+14) * 8
+	aead    *AeadCrypt
+	buffer  []byte        // left bytes not decoded in rBuf.
+	rBuf    []byte        // reused by every receive.
+	bufSize int           // default is (1518 + 20+20+14) * 8
+	batch   int           // bytes to coalesce frames into one write, 0 is disabled.
+	delay   time.Duration // to flush frames coalesced.
+	wLock   sync.Mutex
+	wBuf    []byte // frames coalesced.
+	wConn   net.Conn
+	wErr    error // error of flushing by timer.
+	wTimer  *time.Timer
+	wArmed  bool
+	zip     compressValue // negotiated by login.
 }
 
 func (s *StreamMessagerImpl) Flush() {
 	s.buffer = nil
+	s.zip.Store(nil)
+	if s.aead != nil {
+		s.aead.Reset()
+	}
+	s.wLock.Lock()
+	s.wBuf = s.wBuf[:0]
+	s.wConn = nil
+	s.wErr = nil
+	if s.wTimer != nil {
+		s.wTimer.Stop()
+	}
+	s.wArmed = false
+	s.wLock.Unlock()
+}
+
+func (s *StreamMessagerImpl) Crypt() *AeadCrypt {
+	return s.aead
+}
+
+func (s *StreamMessagerImpl) SetCompress(c Compressor) {
+	s.zip.Store(c)
 }
 
 func (s *StreamMessagerImpl) write(conn net.Conn, tmp []byte) (int, error) {
@@ -278,22 +463,89 @@ func (s *StreamMessagerImpl) writeX(conn net.Conn, buf []byte) error {
 	return nil
 }
 
-func (s *StreamMessagerImpl) decode(frame *FrameMessage) {
-	frame.buffer[0] = MAGIC[0]
-	frame.buffer[1] = MAGIC[1]
-	binary.BigEndian.PutUint16(frame.buffer[HlMI:HlLI], uint16(frame.size))
+// batchX coalesces frames into one write, which is flushed when full
+// or after delay. A control frame is flushed with frames before it at
+// once.
+func (s *StreamMessagerImpl) batchX(conn net.Conn, buf []byte, control bool) error {
+	s.wLock.Lock()
+	defer s.wLock.Unlock()
+	if err := s.wErr; err != nil {
+		s.wErr = nil
+		return err
+	}
+	if s.wBuf == nil {
+		s.wBuf = make([]byte, 0, s.batch+HlSize+MaxBuf)
+	}
+	if len(s.wBuf) > 0 && (s.wConn != conn || len(s.wBuf)+len(buf) > s.batch) {
+		if err := s.flushX(); err != nil {
+			return err
+		}
+	}
+	s.wConn = conn
+	s.wBuf = append(s.wBuf, buf...)
+	if control || len(s.wBuf) >= s.batch {
+		return s.flushX()
+	}
+	if !s.wArmed {
+		s.wArmed = true
+		if s.wTimer == nil {
+			s.wTimer = time.AfterFunc(s.delay, s.onFlush)
+		} else {
+			s.wTimer.Reset(s.delay)
+		}
+	}
+	return nil
+}
+
+func (s *StreamMessagerImpl) onFlush() {
+	s.wLock.Lock()
+	defer s.wLock.Unlock()
+	s.wArmed = false
+	if err := s.flushX(); err != nil {
+		s.wErr = err
+	}
+}
+
+func (s *StreamMessagerImpl) flushX() error {
+	if len(s.wBuf) == 0 {
+		return nil
+	}
+	err := s.writeX(s.wConn, s.wBuf)
+	s.wBuf = s.wBuf[:0]
+	return err
+}
+
+func (s *StreamMessagerImpl) decode(frame *FrameMessage, flag uint16) {
+	putHeader(frame.buffer, frame.channel, uint16(frame.size)|flag)
 	if s.block != nil {
 		s.block.Encrypt(frame.frame, frame.frame)
 	}
 }
 
 func (s *StreamMessagerImpl) Send(conn net.Conn, frame *FrameMessage) (int, error) {
-	s.decode(frame)
-	fs := frame.size + HlSize
-	if err := s.writeX(conn, frame.buffer[:fs]); err != nil {
+	var buf []byte
+	var flag uint16
+	data := frame
+	if zip := compressFrame(s.zip.Load(), frame); zip != nil {
+		defer zip.Free()
+		data, flag = zip, HlCompress
+	}
+	if s.aead != nil {
+		buf, frame.seq = s.aead.SealFlag(data.frame[:data.size], frame.channel, flag)
+	} else {
+		s.decode(data, flag)
+		buf = data.buffer[:data.size+HlSize]
+	}
+	if s.batch > 0 {
+		if err := s.batchX(conn, buf, frame.IsControl()); err != nil {
+			return 0, err
+		}
+		return len(buf), nil
+	}
+	if err := s.writeX(conn, buf); err != nil {
 		return 0, err
 	}
-	return fs, nil
+	return len(buf), nil
 }
 
 func (s *StreamMessagerImpl) read(conn net.Conn, tmp []byte) (int, error) {
@@ -321,12 +573,10 @@ func (s *StreamMessagerImpl) readX(conn net.Conn, buf []byte) error {
 		Log("StreamMessagerImpl.readX: %s %d", conn.RemoteAddr(), len(buf))
 	}
 	for left > 0 {
-		tmp := make([]byte, left)
-		n, err := s.read(conn, tmp)
+		n, err := s.read(conn, buf[offset:])
 		if err != nil {
 			return err
 		}
-		copy(buf[offset:], tmp)
 		offset += n
 		left -= n
 	}
@@ -341,19 +591,44 @@ func (s *StreamMessagerImpl) encode(tmp []byte, min int) (*FrameMessage, error)
 	if ts < min {
 		return nil, nil
 	}
-	if !bytes.Equal(tmp[:HlMI], MAGIC[:HlMI]) {
+	if tmp[0] != MAGIC[0] {
 		return nil, NewErr("wrong magic")
 	}
 	ps := binary.BigEndian.Uint16(tmp[HlMI:HlLI])
-	fs := int(ps) + HlSize
-	if ts >= fs {
-		s.buffer = tmp[fs:]
+	fs := int(ps&HlLenMask) + HlSize
+	if ts < fs {
+		return nil, nil
+	}
+	s.buffer = tmp[fs:]
+	var frame *FrameMessage
+	var err error
+	if s.aead != nil {
+		plain, seq, err := s.aead.Open(tmp[:fs])
+		if err != nil {
+			return nil, err
+		}
+		if ps&HlCompress != 0 {
+			if frame, err = decompress(s.zip.Load(), plain); err != nil {
+				return nil, err
+			}
+		} else {
+			frame = NewFrameMessageFromPool(headOf(tmp[:fs], plain))
+		}
+		frame.seq = seq
+	} else {
 		if s.block != nil {
 			s.block.Decrypt(tmp[HlSize:fs], tmp[HlSize:fs])
 		}
-		return NewFrameMessageFromBytes(tmp[:fs]), nil
+		if ps&HlCompress != 0 {
+			if frame, err = decompress(s.zip.Load(), tmp[HlSize:fs]); err != nil {
+				return nil, err
+			}
+		} else {
+			frame = NewFrameMessageFromPool(tmp[:fs])
+		}
 	}
-	return nil, nil
+	frame.channel = channelOf(tmp)
+	return frame, nil
 }
 
 // 430Mib
@@ -368,8 +643,11 @@ func (s *StreamMessagerImpl) Receive(conn net.Conn, max, min int) (*FrameMessage
 	if s.bufSize == 0 {
 		s.bufSize = 12576 // 1572 * 8
 	}
+	if len(s.rBuf) != s.bufSize {
+		s.rBuf = make([]byte, s.bufSize)
+	}
 	bs := len(s.buffer)
-	tmp := make([]byte, s.bufSize)
+	tmp := s.rBuf
 	if bs > 0 {
 		copy(tmp[:bs], s.buffer[:bs])
 	}
@@ -394,18 +672,40 @@ func (s *StreamMessagerImpl) Receive(conn net.Conn, max, min int) (*FrameMessage
 type PacketMessagerImpl struct {
 	timeout time.Duration // ns for read and write deadline
 	block   kcp.BlockCrypt
+	aead    *AeadCrypt
+	zip     compressValue // negotiated by login.
 }
 
 func (s *PacketMessagerImpl) Flush() {
-	//TODO
+	s.zip.Store(nil)
+	if s.aead != nil {
+		s.aead.Reset()
+	}
+}
+
+func (s *PacketMessagerImpl) Crypt() *AeadCrypt {
+	return s.aead
+}
+
+func (s *PacketMessagerImpl) SetCompress(c Compressor) {
+	s.zip.Store(c)
 }
 
 func (s *PacketMessagerImpl) Send(conn net.Conn, frame *FrameMessage) (int, error) {
-	frame.buffer[0] = MAGIC[0]
-	frame.buffer[1] = MAGIC[1]
-	binary.BigEndian.PutUint16(frame.buffer[HlMI:HlLI], uint16(frame.size))
-	if s.block != nil {
-		s.block.Encrypt(frame.frame, frame.frame)
+	var flag uint16
+	data := frame
+	if zip := compressFrame(s.zip.Load(), frame); zip != nil {
+		defer zip.Free()
+		data, flag = zip, HlCompress
+	}
+	buf := data.buffer[:HlSize+data.size]
+	if s.aead != nil {
+		buf, frame.seq = s.aead.SealFlag(data.frame[:data.size], frame.channel, flag)
+	} else {
+		putHeader(buf, frame.channel, uint16(data.size)|flag)
+		if s.block != nil {
+			s.block.Encrypt(data.frame, data.frame)
+		}
 	}
 	if HasLog(DEBUG) {
 		Debug("PacketMessagerImpl.Send: %s %x", conn.RemoteAddr(), frame)
@@ -416,45 +716,101 @@ func (s *PacketMessagerImpl) Send(conn net.Conn, frame *FrameMessage) (int, erro
 			return 0, err
 		}
 	}
-	if _, err := conn.Write(frame.buffer[:HlSize+frame.size]); err != nil {
+	if _, err := conn.Write(buf); err != nil {
 		return 0, err
 	}
 	return frame.size, nil
 }
 
-func (s *PacketMessagerImpl) Receive(conn net.Conn, max, min int) (*FrameMessage, error) {
-	frame := NewFrameMessage()
-	if HasLog(DEBUG) {
-		Debug("PacketMessagerImpl.Receive %s %d", conn.RemoteAddr(), s.timeout)
-	}
+func (s *PacketMessagerImpl) read(conn net.Conn, frame *FrameMessage) (int, error) {
 	if s.timeout != 0 {
 		err := conn.SetReadDeadline(time.Now().Add(s.timeout))
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 	}
 	n, err := conn.Read(frame.buffer)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	if HasLog(DEBUG) {
 		Debug("PacketMessagerImpl.Receive: %s %x", conn.RemoteAddr(), frame.buffer[:n])
 	}
 	if n <= 4 {
-		return nil, NewErr("%s: small frame", conn.RemoteAddr())
+		return 0, NewErr("%s: small frame", conn.RemoteAddr())
 	}
-	if !bytes.Equal(frame.buffer[:HlMI], MAGIC[:HlMI]) {
-		return nil, NewErr("%s: wrong magic", conn.RemoteAddr())
+	if frame.buffer[0] != MAGIC[0] {
+		return 0, NewErr("%s: wrong magic", conn.RemoteAddr())
 	}
-	size := int(binary.BigEndian.Uint16(frame.buffer[HlMI:HlLI]))
-	if size > max || size < min {
-		return nil, NewErr("%s: wrong size %d", conn.RemoteAddr(), size)
+	return n, nil
+}
+
+// open authenticates a datagram, and returns false if it should be dropped.
+// The session roams to source of datagram only after authenticated.
+func (s *PacketMessagerImpl) open(conn net.Conn, frame *FrameMessage, n int) bool {
+	plain, seq, err := s.aead.Open(frame.buffer[:n])
+	if err != nil {
+		Warn("PacketMessagerImpl.Receive: %s %s", conn.RemoteAddr(), err)
+		return false
 	}
-	tmp := frame.buffer[HlSize : HlSize+size]
-	if s.block != nil {
-		s.block.Decrypt(tmp, tmp)
+	if c, ok := conn.(*XDPConn); ok {
+		c.Roam()
+	}
+	frame.seq = seq
+	frame.buffer = frame.buffer[cap(frame.buffer)-cap(plain)-HlSize:]
+	frame.frame = plain
+	frame.size = len(plain)
+	return true
+}
+
+func (s *PacketMessagerImpl) Receive(conn net.Conn, max, min int) (*FrameMessage, error) {
+	if HasLog(DEBUG) {
+		Debug("PacketMessagerImpl.Receive %s %d", conn.RemoteAddr(), s.timeout)
+	}
+	for {
+		frame := NewFrameMessage()
+		n, err := s.read(conn, frame)
+		if err != nil {
+			frame.Free()
+			return nil, err
+		}
+		ps := binary.BigEndian.Uint16(frame.buffer[HlMI:HlLI])
+		channel := channelOf(frame.buffer)
+		if s.aead != nil {
+			// dropping forged or replayed datagram, and continue to read.
+			if !s.open(conn, frame, n) {
+				frame.Free()
+				continue
+			}
+		} else {
+			size := int(ps & HlLenMask)
+			if size > max || size < min && ps&HlCompress == 0 {
+				frame.Free()
+				return nil, NewErr("%s: wrong size %d", conn.RemoteAddr(), size)
+			}
+			tmp := frame.buffer[HlSize : HlSize+size]
+			if s.block != nil {
+				s.block.Decrypt(tmp, tmp)
+			}
+			frame.size = size
+			frame.frame = tmp
+		}
+		if ps&HlCompress != 0 {
+			plain, err := decompress(s.zip.Load(), frame.frame[:frame.size])
+			seq := frame.seq
+			frame.Free()
+			if err != nil {
+				Warn("PacketMessagerImpl.Receive: %s %s", conn.RemoteAddr(), err)
+				continue
+			}
+			frame = plain
+			frame.seq = seq
+		}
+		if frame.size > max || frame.size < min {
+			frame.Free()
+			return nil, NewErr("%s: wrong size %d", conn.RemoteAddr(), frame.size)
+		}
+		frame.channel = channel
+		return frame, nil
 	}
-	frame.size = size
-	frame.frame = tmp
-	return frame, nil
 }
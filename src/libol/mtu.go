@@ -0,0 +1,124 @@
+package libol
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// MtuPaths are IP MTUs of path probed, and the frame of probe is smaller
+// by overhead of tunnel. The largest frame delivered is MTU of tunnel plus
+// ethernet header.
+var MtuPaths = []int{1500, 1492, 1480, 1460, 1400, 1350, 1280}
+
+// MtuMin is the smallest MTU reported by point accepted by switch.
+const MtuMin = 576
+
+// MtuProbe is body of probe, and probe with mtu reports result of it.
+type MtuProbe struct {
+	Size int `json:"size,omitempty"`
+	Mtu  int `json:"mtu,omitempty"`
+}
+
+// NewMtuProbe returns request padded to size of frame, and padding is
+// random to not be compressed.
+func NewMtuProbe(size int) *FrameMessage {
+	body, _ := json.Marshal(&MtuProbe{Size: size})
+	frame := NewControlFrame(MtuReq, body)
+	if pad := size - frame.Size(); pad > 0 {
+		data := make([]byte, pad)
+		_, _ = rand.Read(data)
+		frame.Append(data)
+	}
+	return frame
+}
+
+// DecodeMtuProbe ignores padding of probe.
+func DecodeMtuProbe(data []byte) (*MtuProbe, error) {
+	probe := &MtuProbe{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(probe); err != nil {
+		return nil, err
+	}
+	return probe, nil
+}
+
+// ClampMss lowers MSS option of TCP SYN in ethernet frame to fit into
+// IP MTU, and returns true if changed.
+func ClampMss(frame []byte, mtu int) bool {
+	if mtu <= 0 || len(frame) < EtherLen {
+		return false
+	}
+	data := frame[EtherLen:]
+	var tcp []byte
+	var mss int
+	switch binary.BigEndian.Uint16(frame[12:14]) {
+	case EthIp4:
+		if len(data) < Ipv4Len || data[9] != IpTcp {
+			return false
+		}
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff != 0 { // fragment.
+			return false
+		}
+		hl := int(data[0]&0x0f) * 4
+		if hl < Ipv4Len || len(data) < hl {
+			return false
+		}
+		tcp = data[hl:]
+		mss = mtu - Ipv4Len - TcpLen
+	case EthIp6:
+		if len(data) < Ipv6Len || data[6] != IpTcp {
+			return false
+		}
+		tcp = data[Ipv6Len:]
+		mss = mtu - Ipv6Len - TcpLen
+	default:
+		return false
+	}
+	if mss <= 0 || len(tcp) < TcpLen || tcp[13]&TcpSyn == 0 {
+		return false
+	}
+	off := int(tcp[12]>>4) * 4
+	if off < TcpLen || len(tcp) < off {
+		return false
+	}
+	for i := TcpLen; i < off; {
+		kind := tcp[i]
+		if kind == 0 { // end of options.
+			break
+		}
+		if kind == 1 { // no operation.
+			i++
+			continue
+		}
+		if i+1 >= off || tcp[i+1] < 2 || i+int(tcp[i+1]) > off {
+			break
+		}
+		if kind == 2 && tcp[i+1] == 4 {
+			old := binary.BigEndian.Uint16(tcp[i+2 : i+4])
+			if int(old) <= mss {
+				return false
+			}
+			binary.BigEndian.PutUint16(tcp[i+2:i+4], uint16(mss))
+			updateChecksum(tcp[16:18], old, uint16(mss), i%2 == 1)
+			return true
+		}
+		i += int(tcp[i+1])
+	}
+	return false
+}
+
+// updateChecksum updates checksum incrementally by RFC 1624, and swap
+// if the value changed is not aligned by 16 bits.
+func updateChecksum(sum []byte, old, new uint16, swap bool) {
+	if swap {
+		old = old<<8 | old>>8
+		new = new<<8 | new>>8
+	}
+	s := uint32(^binary.BigEndian.Uint16(sum)) + uint32(^old) + uint32(new)
+	for s>>16 != 0 {
+		s = (s & 0xffff) + (s >> 16)
+	}
+	binary.BigEndian.PutUint16(sum, ^uint16(s))
+}
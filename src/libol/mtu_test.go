@@ -0,0 +1,65 @@
+package libol
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func sumOf(data []byte) uint16 {
+	sum := uint32(0)
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i : i+2]))
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return uint16(sum)
+}
+
+func newSyn(opts []byte) []byte {
+	frame := make([]byte, EtherLen+Ipv4Len+TcpLen+len(opts))
+	binary.BigEndian.PutUint16(frame[12:14], EthIp4)
+	ip := frame[EtherLen:]
+	ip[0] = 0x45
+	ip[9] = IpTcp
+	tcp := ip[Ipv4Len:]
+	tcp[12] = uint8((TcpLen+len(opts))/4) << 4
+	tcp[13] = TcpSyn
+	binary.BigEndian.PutUint16(tcp[16:18], 0x1234)
+	copy(tcp[TcpLen:], opts)
+	return frame
+}
+
+func TestClampMss(t *testing.T) {
+	for _, opts := range [][]byte{
+		{2, 4, 0x05, 0xb4, 1, 1, 1, 0}, // aligned.
+		{1, 2, 4, 0x05, 0xb4, 1, 1, 0}, // not aligned.
+		{1, 1, 4, 2, 2, 4, 0x05, 0xb4}, // after sack permitted.
+	} {
+		frame := newSyn(opts)
+		tcp := frame[EtherLen+Ipv4Len:]
+		sum := sumOf(tcp)
+		assert.Equal(t, true, ClampMss(frame, 1400), "be the same.")
+		assert.Equal(t, sum, sumOf(tcp), "be the same.")
+		assert.Equal(t, false, ClampMss(frame, 1400), "be the same.")
+		assert.Equal(t, false, ClampMss(frame, 1500), "be the same.")
+	}
+	frame := newSyn([]byte{2, 4, 0x05, 0xb4})
+	ClampMss(frame, 1400)
+	assert.Equal(t, uint16(1360), binary.BigEndian.Uint16(frame[EtherLen+Ipv4Len+TcpLen+2:]), "be the same.")
+	// not syn.
+	frame = newSyn([]byte{2, 4, 0x05, 0xb4})
+	frame[EtherLen+Ipv4Len+13] = TcpAck
+	assert.Equal(t, false, ClampMss(frame, 1400), "be the same.")
+}
+
+func TestMtuProbe(t *testing.T) {
+	frame := NewMtuProbe(1400)
+	assert.Equal(t, 1400, frame.Size(), "be the same.")
+	frame.Decode()
+	_, params := frame.CmdAndParams()
+	probe, err := DecodeMtuProbe(params)
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, 1400, probe.Size, "be the same.")
+}
@@ -29,7 +29,10 @@ const (
 	VlanLen  = 4
 	TcpLen   = 20
 	Ipv4Len  = 20
+	Ipv6Len  = 40
 	UdpLen   = 8
+	Icmp6Len = 4
+	NdpLen   = 20
 )
 
 func NewEther(t uint16) (e *Ether) {
@@ -91,6 +94,10 @@ func (e *Ether) IsIP4() bool {
 	return e.Type == EthIp4
 }
 
+func (e *Ether) IsIP6() bool {
+	return e.Type == EthIp6
+}
+
 type Vlan struct {
 	Tci uint16
 	Vid uint16
@@ -264,17 +271,26 @@ const (
 )
 
 const (
-	IpIcmp = 0x01
-	IpIgmp = 0x02
-	IpIpIp = 0x04
-	IpTcp  = 0x06
-	IpUdp  = 0x11
-	IpEsp  = 0x32
-	IpAh   = 0x33
-	IpOspf = 0x59
-	IpPim  = 0x67
-	IpVrrp = 0x70
-	IpIsis = 0x7c
+	IpIcmp  = 0x01
+	IpIgmp  = 0x02
+	IpIpIp  = 0x04
+	IpTcp   = 0x06
+	IpUdp   = 0x11
+	IpEsp   = 0x32
+	IpAh    = 0x33
+	IpOspf  = 0x59
+	IpPim   = 0x67
+	IpVrrp  = 0x70
+	IpIsis  = 0x7c
+	IpIcmp6 = 0x3a
+)
+
+const (
+	Ip6HopByHop = 0x00
+	Ip6Routing  = 0x2b
+	Ip6Fragment = 0x2c
+	Ip6NoNext   = 0x3b
+	Ip6DstOpts  = 0x3c
 )
 
 func IpProto2Str(proto uint8) string {
@@ -301,6 +317,8 @@ func IpProto2Str(proto uint8) string {
 		return "pim"
 	case IpVrrp:
 		return "vrrp"
+	case IpIcmp6:
+		return "icmpv6"
 	default:
 		return fmt.Sprintf("%02x", proto)
 	}
@@ -398,6 +416,272 @@ func (i *Ipv4) IsIP4() bool {
 	return i.Version == Ipv4Ver
 }
 
+type Ipv6 struct {
+	Version      uint8 //4bit
+	TrafficClass uint8
+	FlowLabel    uint32 //20bit
+	PayloadLen   uint16
+	NextHeader   uint8
+	HopLimit     uint8
+	Source       []byte
+	Destination  []byte
+	Protocol     uint8 // upper layer after extension headers.
+	Len          int   // includes extension headers.
+}
+
+func NewIpv6() (i *Ipv6) {
+	i = &Ipv6{
+		Version:     Ipv6Ver,
+		HopLimit:    0xff,
+		Len:         Ipv6Len,
+		Source:      make([]byte, 16),
+		Destination: make([]byte, 16),
+	}
+	return
+}
+
+func NewIpv6FromFrame(frame []byte) (i *Ipv6, err error) {
+	i = NewIpv6()
+	err = i.Decode(frame)
+	return
+}
+
+func (i *Ipv6) Decode(frame []byte) error {
+	if len(frame) < Ipv6Len {
+		return NewErr("Ipv6.Decode: too small header: %d", len(frame))
+	}
+
+	v := binary.BigEndian.Uint32(frame[0:4])
+	i.Version = uint8(v >> 28)
+	i.TrafficClass = uint8(v >> 20)
+	i.FlowLabel = v & 0x000fffff
+	i.PayloadLen = binary.BigEndian.Uint16(frame[4:6])
+	i.NextHeader = uint8(frame[6])
+	i.HopLimit = uint8(frame[7])
+	if !i.IsIP6() {
+		return NewErr("Ipv6.Decode: not right ipv6 version: 0x%x", i.Version)
+	}
+	copy(i.Source[:16], frame[8:24])
+	copy(i.Destination[:16], frame[24:40])
+
+	// walk extension headers to find protocol of upper layer.
+	next := i.NextHeader
+	p := Ipv6Len
+	for {
+		size := 0
+		switch next {
+		case Ip6HopByHop, Ip6Routing, Ip6DstOpts:
+			if len(frame) < p+2 {
+				break
+			}
+			size = (int(frame[p+1]) + 1) * 8
+		case Ip6Fragment:
+			size = 8
+		case IpAh:
+			if len(frame) < p+2 {
+				break
+			}
+			size = (int(frame[p+1]) + 2) * 4
+		default:
+			i.Protocol = next
+			i.Len = p
+			return nil
+		}
+		if size == 0 || len(frame) < p+size {
+			return NewErr("Ipv6.Decode: too small extension header: 0x%x", next)
+		}
+		next = uint8(frame[p])
+		p += size
+	}
+}
+
+func (i *Ipv6) Encode() []byte {
+	buffer := make([]byte, Ipv6Len)
+
+	v := uint32(i.Version)<<28 | uint32(i.TrafficClass)<<20 | i.FlowLabel&0x000fffff
+	binary.BigEndian.PutUint32(buffer[0:4], v)
+	binary.BigEndian.PutUint16(buffer[4:6], i.PayloadLen)
+	buffer[6] = i.NextHeader
+	buffer[7] = i.HopLimit
+	copy(buffer[8:24], i.Source[:16])
+	copy(buffer[24:40], i.Destination[:16])
+
+	return buffer[:Ipv6Len]
+}
+
+func (i *Ipv6) IsIP6() bool {
+	return i.Version == Ipv6Ver
+}
+
+const (
+	Icmp6EchoRequest   = 128
+	Icmp6EchoReply     = 129
+	Icmp6RouterSolicit = 133
+	Icmp6RouterAdvert  = 134
+	Icmp6NeighSolicit  = 135
+	Icmp6NeighAdvert   = 136
+)
+
+type Icmpv6 struct {
+	Type     uint8
+	Code     uint8
+	Checksum uint16
+	Len      int
+}
+
+func NewIcmpv6(t uint8) (c *Icmpv6) {
+	c = &Icmpv6{
+		Type: t,
+		Len:  Icmp6Len,
+	}
+	return
+}
+
+func NewIcmpv6FromFrame(frame []byte) (c *Icmpv6, err error) {
+	c = NewIcmpv6(0)
+	err = c.Decode(frame)
+	return
+}
+
+func (c *Icmpv6) Decode(frame []byte) error {
+	if len(frame) < Icmp6Len {
+		return NewErr("Icmpv6.Decode: too small header: %d", len(frame))
+	}
+
+	c.Type = uint8(frame[0])
+	c.Code = uint8(frame[1])
+	c.Checksum = binary.BigEndian.Uint16(frame[2:4])
+
+	return nil
+}
+
+func (c *Icmpv6) Encode() []byte {
+	buffer := make([]byte, Icmp6Len)
+
+	buffer[0] = c.Type
+	buffer[1] = c.Code
+	binary.BigEndian.PutUint16(buffer[2:4], c.Checksum)
+
+	return buffer[:c.Len]
+}
+
+func (c *Icmpv6) IsNeighbor() bool {
+	return c.Type == Icmp6NeighSolicit || c.Type == Icmp6NeighAdvert
+}
+
+// Icmpv6Checksum returns checksum of ICMPv6 message with pseudo header,
+// and checksum in message must be zero.
+func Icmpv6Checksum(src, dst, data []byte) uint16 {
+	pseudo := make([]byte, 40)
+	copy(pseudo[0:16], src[:16])
+	copy(pseudo[16:32], dst[:16])
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(data)))
+	pseudo[39] = IpIcmp6
+	sum := uint32(0)
+	for _, buf := range [][]byte{pseudo, data} {
+		for i := 0; i+1 < len(buf); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(buf[i : i+2]))
+		}
+		if len(buf)%2 == 1 {
+			sum += uint32(buf[len(buf)-1]) << 8
+		}
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+// Ip6SolicitedNode returns solicited-node multicast address of ip.
+func Ip6SolicitedNode(ip []byte) []byte {
+	addr := []byte{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, 0, 0, 0}
+	copy(addr[13:16], ip[13:16])
+	return addr
+}
+
+// Ip6McastEth returns ethernet address of IPv6 multicast.
+func Ip6McastEth(ip []byte) []byte {
+	addr := []byte{0x33, 0x33, 0, 0, 0, 0}
+	copy(addr[2:6], ip[12:16])
+	return addr
+}
+
+const (
+	NdpSrcLinkAddr = 1
+	NdpDstLinkAddr = 2
+)
+
+const (
+	NdpRouter    = 0x80000000
+	NdpSolicited = 0x40000000
+	NdpOverride  = 0x20000000
+)
+
+// Ndp is neighbor solicitation or advertisement after ICMPv6 header.
+type Ndp struct {
+	Flags  uint32 // reserved for solicitation.
+	Target []byte
+	Option uint8 // type of link-layer address option.
+	HwAddr []byte
+	Len    int
+}
+
+func NewNdp() (n *Ndp) {
+	n = &Ndp{
+		Target: make([]byte, 16),
+		Len:    NdpLen,
+	}
+	return
+}
+
+func NewNdpFromFrame(frame []byte) (n *Ndp, err error) {
+	n = NewNdp()
+	err = n.Decode(frame)
+	return
+}
+
+func (n *Ndp) Decode(frame []byte) error {
+	if len(frame) < NdpLen {
+		return NewErr("Ndp.Decode: too small header: %d", len(frame))
+	}
+
+	n.Flags = binary.BigEndian.Uint32(frame[0:4])
+	copy(n.Target[:16], frame[4:20])
+	n.Len = NdpLen
+	for p := NdpLen; len(frame) >= p+2; {
+		size := int(frame[p+1]) * 8
+		if size == 0 || len(frame) < p+size {
+			return NewErr("Ndp.Decode: invalid option: %d", size)
+		}
+		t := uint8(frame[p])
+		if (t == NdpSrcLinkAddr || t == NdpDstLinkAddr) && size >= 8 {
+			n.Option = t
+			n.HwAddr = make([]byte, 6)
+			copy(n.HwAddr[:6], frame[p+2:p+8])
+		}
+		p += size
+		n.Len = p
+	}
+
+	return nil
+}
+
+func (n *Ndp) Encode() []byte {
+	buffer := make([]byte, NdpLen+8)
+
+	binary.BigEndian.PutUint32(buffer[0:4], n.Flags)
+	copy(buffer[4:20], n.Target[:16])
+	n.Len = NdpLen
+	if n.HwAddr != nil {
+		buffer[20] = n.Option
+		buffer[21] = 1
+		copy(buffer[22:28], n.HwAddr[:6])
+		n.Len += 8
+	}
+
+	return buffer[:n.Len]
+}
+
 const (
 	TcpUrg = 0x20
 	TcpAck = 0x10
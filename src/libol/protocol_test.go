@@ -0,0 +1,60 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestFrameProto_Ipv6Ndp(t *testing.T) {
+	eth := NewEther(EthIp6)
+	copy(eth.Src, []byte{0x00, 0x16, 0x3e, 0x01, 0x02, 0x03})
+	ip := NewIpv6()
+	ip.NextHeader = Ip6HopByHop
+	copy(ip.Source, net.ParseIP("fe80::1"))
+	copy(ip.Destination, net.ParseIP("ff02::1"))
+	// hop-by-hop with padding only.
+	hop := []byte{IpIcmp6, 0, 1, 4, 0, 0, 0, 0}
+	icmp := NewIcmpv6(Icmp6NeighAdvert)
+	ndp := NewNdp()
+	ndp.Flags = NdpSolicited | NdpOverride
+	copy(ndp.Target, net.ParseIP("2001:db8::10"))
+	ndp.Option = NdpDstLinkAddr
+	ndp.HwAddr = []byte{0x00, 0x16, 0x3e, 0x0a, 0x0b, 0x0c}
+
+	frame := append(eth.Encode(), ip.Encode()...)
+	frame = append(frame, hop...)
+	frame = append(frame, icmp.Encode()...)
+	frame = append(frame, ndp.Encode()...)
+	proto := &FrameProto{Frame: frame}
+	assert.Nil(t, proto.Decode(), "be nil.")
+	assert.Equal(t, uint8(Ip6HopByHop), proto.Ip6.NextHeader, "be the same.")
+	assert.Equal(t, uint8(IpIcmp6), proto.Ip6.Protocol, "be the same.")
+	assert.Equal(t, Ipv6Len+len(hop), proto.Ip6.Len, "be the same.")
+	assert.Equal(t, uint8(Icmp6NeighAdvert), proto.Icmp6.Type, "be the same.")
+	assert.Equal(t, ndp.Target, proto.Ndp.Target, "be the same.")
+	assert.Equal(t, ndp.HwAddr, proto.Ndp.HwAddr, "be the same.")
+	assert.Equal(t, uint8(NdpDstLinkAddr), proto.Ndp.Option, "be the same.")
+
+	frame = append(eth.Encode(), ip.Encode()...)
+	frame = append(frame, hop[:4]...)
+	proto = &FrameProto{Frame: frame}
+	assert.NotNil(t, proto.Decode(), "too small.")
+}
+
+func TestIcmpv6Checksum(t *testing.T) {
+	src := net.ParseIP("fe80::1")
+	dst := Ip6SolicitedNode(net.ParseIP("2001:db8::10"))
+	assert.Equal(t, net.ParseIP("ff02::1:ff00:10"), net.IP(dst), "be the same.")
+	assert.Equal(t, []byte{0x33, 0x33, 0xff, 0x00, 0x00, 0x10}, Ip6McastEth(dst), "be the same.")
+
+	icmp := NewIcmpv6(Icmp6NeighSolicit)
+	ndp := NewNdp()
+	copy(ndp.Target, net.ParseIP("2001:db8::10"))
+	data := append(icmp.Encode(), ndp.Encode()...)
+	icmp.Checksum = Icmpv6Checksum(src, dst, data)
+	assert.NotEqual(t, uint16(0), icmp.Checksum, "not zero.")
+	data = append(icmp.Encode(), ndp.Encode()...)
+	// sum of message with checksum is all ones.
+	assert.Equal(t, uint16(0), Icmpv6Checksum(src, dst, data), "be the same.")
+}
@@ -0,0 +1,301 @@
+package libol
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Proxy dials address of switch through upstream proxy by HTTP CONNECT
+// or SOCKS5, and url is http://, https:// or socks5:// with credentials.
+type Proxy struct {
+	url     *url.URL
+	noProxy []string // hosts dialed directly.
+	Timeout time.Duration
+}
+
+// NewProxy returns proxy of address, and it is http if no scheme.
+func NewProxy(addr string) (*Proxy, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	port := ""
+	switch u.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	case "socks5":
+		port = "1080"
+	default:
+		return nil, NewErr("proxy %s notSupport", u.Scheme)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return &Proxy{
+		url:     u,
+		noProxy: noProxyFromEnv(),
+		Timeout: 10 * time.Second,
+	}, nil
+}
+
+// ProxyFromEnv returns proxy in HTTPS_PROXY of environment.
+func ProxyFromEnv() string {
+	for _, key := range []string{"HTTPS_PROXY", "https_proxy"} {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// noProxyFromEnv returns hosts in NO_PROXY of environment.
+func noProxyFromEnv() []string {
+	hosts := make([]string, 0, 8)
+	for _, key := range []string{"NO_PROXY", "no_proxy"} {
+		v := os.Getenv(key)
+		if v == "" {
+			continue
+		}
+		for _, host := range strings.Split(v, ",") {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		break
+	}
+	return hosts
+}
+
+// Bypass returns true if address matches host, domain, IP or CIDR in
+// NO_PROXY, and it is dialed directly.
+func (p *Proxy) Bypass(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, item := range p.noProxy {
+		if item == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(item); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, pt, err := net.SplitHostPort(item); err == nil {
+			if pt != port {
+				continue
+			}
+			item = h
+		}
+		item = strings.Trim(item, "[]")
+		if other := net.ParseIP(item); other != nil {
+			if ip != nil && ip.Equal(other) {
+				return true
+			}
+			continue
+		}
+		item = strings.TrimPrefix(strings.TrimPrefix(item, "*"), ".")
+		if host == item || strings.HasSuffix(host, "."+item) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Proxy) String() string {
+	return p.url.Scheme + "://" + p.url.Host
+}
+
+// Dial returns connection tunneled to address.
+func (p *Proxy) Dial(addr string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", p.url.Host, p.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(p.Timeout))
+	if p.url.Scheme == "https" {
+		tc := tls.Client(conn, &tls.Config{ServerName: p.url.Hostname()})
+		if err := tc.Handshake(); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	if p.url.Scheme == "socks5" {
+		err = p.socks5(conn, addr)
+	} else {
+		conn, err = p.connect(conn, addr)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// DialTls returns connection tunneled to address, and handshakes TLS by
+// cfg if it is not nil.
+func (p *Proxy) DialTls(addr string, cfg *tls.Config) (net.Conn, error) {
+	conn, err := p.Dial(addr)
+	if err != nil || cfg == nil {
+		return conn, err
+	}
+	if cfg.ServerName == "" {
+		cfg = cfg.Clone()
+		cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tc := tls.Client(conn, cfg)
+	if err := tc.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// bufConn reads data buffered by response of proxy firstly.
+type bufConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (p *Proxy) connect(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := p.url.User; user != nil {
+		pass, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", BasicAuth(user.Username(), pass))
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return conn, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return conn, NewErr("proxy %s: %s", p, resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		return &bufConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+func (p *Proxy) socks5(conn net.Conn, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	}
+	// methods of no authentication and username/password.
+	if _, err := conn.Write([]byte{0x05, 0x02, 0x00, 0x02}); err != nil {
+		return err
+	}
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return err
+	}
+	switch buf[1] {
+	case 0x00:
+	case 0x02:
+		user := p.url.User
+		if user == nil {
+			return NewErr("proxy %s: need auth", p)
+		}
+		name := user.Username()
+		pass, _ := user.Password()
+		if len(name) > 255 || len(pass) > 255 {
+			return NewErr("proxy %s: auth too long", p)
+		}
+		auth := []byte{0x01, byte(len(name))}
+		auth = append(auth, name...)
+		auth = append(auth, byte(len(pass)))
+		auth = append(auth, pass...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return err
+		}
+		if buf[1] != 0x00 {
+			return NewErr("proxy %s: auth failed", p)
+		}
+	default:
+		return NewErr("proxy %s: method %d notSupport", p, buf[1])
+	}
+	// connect by IP address, or by domain name and proxy resolves it.
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return NewErr("proxy %s: host too long", p)
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 0x01)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(portNum>>8), byte(portNum))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return err
+	}
+	if buf[1] != 0x00 {
+		return NewErr("proxy %s: reply %d", p, buf[1])
+	}
+	// skip bound address and port.
+	size := 0
+	switch buf[3] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x04:
+		size = net.IPv6len
+	case 0x03:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return err
+		}
+		size = int(buf[0])
+	default:
+		return NewErr("proxy %s: address %d notSupport", p, buf[3])
+	}
+	if _, err := io.ReadFull(conn, buf[:size+2]); err != nil {
+		return err
+	}
+	return nil
+}
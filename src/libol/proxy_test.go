@@ -0,0 +1,228 @@
+package libol
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func listenEcho(t *testing.T) net.Listener {
+	ln, err := listenEchoAt("127.0.0.1:0")
+	assert.Equal(t, nil, err, "be the same.")
+	return ln
+}
+
+func listenEchoAt(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	return ln, nil
+}
+
+// serveConnect accepts CONNECT with auth, and tunnels to address of it.
+func serveConnect(ln net.Listener, auth string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err != nil || req.Header.Get("Proxy-Authorization") != auth {
+				_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+				_ = conn.Close()
+				return
+			}
+			up, err := net.Dial("tcp", req.Host)
+			if err != nil {
+				_ = conn.Close()
+				return
+			}
+			_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+			go func() { _, _ = io.Copy(up, conn) }()
+			_, _ = io.Copy(conn, up)
+			_ = conn.Close()
+		}()
+	}
+}
+
+// serveSocks5 accepts username and password, and tunnels to address of
+// type sent to types.
+func serveSocks5(ln net.Listener, types chan byte) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			buf := make([]byte, 512)
+			_, _ = io.ReadFull(conn, buf[:4])
+			_, _ = conn.Write([]byte{0x05, 0x02})
+			_, _ = io.ReadFull(conn, buf[:2]) // version and length of user.
+			user := buf[1]
+			_, _ = io.ReadFull(conn, buf[:user+1]) // user and length of password.
+			_, _ = io.ReadFull(conn, buf[:buf[user]])
+			_, _ = conn.Write([]byte{0x01, 0x00})
+			_, _ = io.ReadFull(conn, buf[:4])
+			atyp := buf[3]
+			if types != nil {
+				types <- atyp
+			}
+			size := net.IPv4len
+			switch atyp {
+			case 0x03:
+				_, _ = io.ReadFull(conn, buf[:1])
+				size = int(buf[0])
+			case 0x04:
+				size = net.IPv6len
+			}
+			_, _ = io.ReadFull(conn, buf[:size+2])
+			host := string(buf[:size])
+			if atyp != 0x03 {
+				host = net.IP(buf[:size]).String()
+			}
+			port := int(buf[size])<<8 | int(buf[size+1])
+			up, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+			if err != nil {
+				_ = conn.Close()
+				return
+			}
+			_, _ = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+			go func() { _, _ = io.Copy(up, conn) }()
+			_, _ = io.Copy(conn, up)
+			_ = conn.Close()
+		}()
+	}
+}
+
+func TestProxy_Dial(t *testing.T) {
+	echo := listenEcho(t)
+	defer echo.Close()
+	hp, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer hp.Close()
+	go serveConnect(hp, BasicAuth("hi", "pass"))
+	sp, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer sp.Close()
+	go serveSocks5(sp, nil)
+
+	for _, addr := range []string{
+		"http://hi:pass@" + hp.Addr().String(),
+		"socks5://hi:pass@" + sp.Addr().String(),
+	} {
+		proxy, err := NewProxy(addr)
+		assert.Equal(t, nil, err, "be the same.")
+		conn, err := proxy.Dial(echo.Addr().String())
+		assert.Equal(t, nil, err, "be the same.")
+		if err != nil {
+			continue
+		}
+		_, _ = conn.Write([]byte("hello"))
+		data := make([]byte, 5)
+		_, err = io.ReadFull(conn, data)
+		assert.Equal(t, nil, err, "be the same.")
+		assert.Equal(t, "hello", string(data), "be the same.")
+		_ = conn.Close()
+	}
+	proxy, _ := NewProxy("http://hi:wrong@" + hp.Addr().String())
+	_, err := proxy.Dial(echo.Addr().String())
+	assert.NotEqual(t, nil, err, "not nil.")
+}
+
+func TestProxy_Socks5Address(t *testing.T) {
+	sp, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer sp.Close()
+	types := make(chan byte, 1)
+	go serveSocks5(sp, types)
+	proxy, _ := NewProxy("socks5://hi:pass@" + sp.Addr().String())
+
+	echo := listenEcho(t)
+	defer echo.Close()
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+	cases := []struct {
+		addr string
+		atyp byte
+	}{
+		{echo.Addr().String(), 0x01},
+		{net.JoinHostPort("localhost", port), 0x03},
+	}
+	if ln, err := listenEchoAt("[::1]:0"); err == nil {
+		defer ln.Close()
+		cases = append(cases, struct {
+			addr string
+			atyp byte
+		}{ln.Addr().String(), 0x04})
+	}
+	for i, c := range cases {
+		conn, err := proxy.Dial(c.addr)
+		assert.Equal(t, nil, err, "case %d be the same.", i)
+		assert.Equal(t, c.atyp, <-types, "case %d be the same.", i)
+		if err != nil {
+			continue
+		}
+		_, _ = conn.Write([]byte("hello"))
+		data := make([]byte, 5)
+		_, err = io.ReadFull(conn, data)
+		assert.Equal(t, nil, err, "case %d be the same.", i)
+		assert.Equal(t, "hello", string(data), "case %d be the same.", i)
+		_ = conn.Close()
+	}
+}
+
+func TestNewProxy_NoScheme(t *testing.T) {
+	proxy, err := NewProxy("proxy:3128")
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, "http://proxy:3128", proxy.String(), "be the same.")
+	proxy, err = NewProxy("hi:pass@proxy")
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, "http://proxy:80", proxy.String(), "be the same.")
+	_, err = NewProxy("ftp://proxy")
+	assert.NotEqual(t, nil, err, "not nil.")
+}
+
+func TestProxy_Bypass(t *testing.T) {
+	_ = os.Setenv("NO_PROXY", "openlan.net, .example.com,10.0.0.0/8,192.168.1.1,[fd00::1],host:10003")
+	defer os.Unsetenv("NO_PROXY")
+	proxy, err := NewProxy("http://proxy:3128")
+	assert.Equal(t, nil, err, "be the same.")
+	cases := []struct {
+		addr   string
+		bypass bool
+	}{
+		{"openlan.net:10002", true},
+		{"sw.openlan.net:10002", true},
+		{"xopenlan.net:10002", false},
+		{"a.example.com:10002", true},
+		{"example.com:10002", true},
+		{"10.1.2.3:10002", true},
+		{"11.1.2.3:10002", false},
+		{"192.168.1.1:10002", true},
+		{"[fd00::1]:10002", true},
+		{"host:10003", true},
+		{"host:10002", false},
+		{"other:10002", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.bypass, proxy.Bypass(c.addr), c.addr)
+	}
+	_ = os.Setenv("NO_PROXY", "*")
+	proxy, _ = NewProxy("http://proxy:3128")
+	assert.Equal(t, true, proxy.Bypass("other:10002"), "be the same.")
+}
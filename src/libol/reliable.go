@@ -0,0 +1,198 @@
+package libol
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	RelTick    = 200 * time.Millisecond // to check frames not acknowledged.
+	RelRto     = 500 * time.Millisecond // first timeout to retransmit.
+	RelMaxRto  = 4 * time.Second
+	RelRetries = 6
+	RelWindow  = 60 // seconds to remember sequences received.
+)
+
+type relFrame struct {
+	data    []byte
+	next    time.Time
+	rto     time.Duration
+	retries int
+}
+
+// Reliable gives control frames a sequence, and retransmits them until
+// acknowledged or answered by peer. It drops the duplicated ones received,
+// and responses refer to the sequence of request, so that control works
+// over lossy transport as udp.
+type Reliable struct {
+	lock    sync.Mutex
+	seq     uint32
+	pending map[uint32]*relFrame
+	recvd   map[uint32]int64
+	lastReq map[string]uint32 // last request received by action.
+	timer   *time.Timer
+	send    func(frame *FrameMessage) error
+	out     *SubLogger
+}
+
+func NewReliable(send func(frame *FrameMessage) error, out *SubLogger) *Reliable {
+	r := &Reliable{
+		send: send,
+		out:  out,
+	}
+	r.Reset()
+	return r
+}
+
+// Reset forgets all states, and is called when connection changed.
+func (r *Reliable) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.seq = 0
+	r.pending = make(map[uint32]*relFrame, 32)
+	r.recvd = make(map[uint32]int64, 32)
+	r.lastReq = make(map[string]uint32, 32)
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// Should returns true if the action is sequenced, and keepalive,
+// handshake or probe of MTU is not.
+func (r *Reliable) Should(action string) bool {
+	switch action {
+	case PingReq, PongResp, HandReq, HandResp, AckResp, MtuReq, MtuResp,
+		ProbeReq, ProbeResp:
+		return false
+	}
+	return len(action) == EthDI
+}
+
+// Requested returns true if request of the response was sequenced, and
+// peer understands the sequence even before capabilities negotiated.
+func (r *Reliable) Requested(action string) bool {
+	if len(action) != EthDI || action[EthDI-2] != ':' {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, ok := r.lastReq[action[:EthDI-2]]
+	return ok
+}
+
+// Wrap returns a new frame with sequence, and the response refers to
+// the last request of the same action.
+func (r *Reliable) Wrap(frame *FrameMessage) *FrameMessage {
+	action, params := frame.CmdAndParams()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.seq++
+	if r.seq == 0 {
+		r.seq++
+	}
+	ref := uint32(0)
+	name := action[:EthDI-2]
+	if action[EthDI-2] == ':' {
+		ref = r.lastReq[name]
+		delete(r.lastReq, name)
+	}
+	wrap := NewSeqControlFrame(action, r.seq, ref, params)
+	data := make([]byte, wrap.size)
+	copy(data, wrap.frame[:wrap.size])
+	r.pending[r.seq] = &relFrame{
+		data: data,
+		next: time.Now().Add(RelRto),
+		rto:  RelRto,
+	}
+	if r.timer == nil {
+		r.timer = time.AfterFunc(RelTick, r.onTimer)
+	}
+	return wrap
+}
+
+func (r *Reliable) onTimer() {
+	now := time.Now()
+	resend := make([][]byte, 0, 8)
+	r.lock.Lock()
+	for seq, p := range r.pending {
+		if now.Before(p.next) {
+			continue
+		}
+		if p.retries >= RelRetries {
+			r.out.Warn("Reliable.onTimer: %d not acknowledged", seq)
+			delete(r.pending, seq)
+			continue
+		}
+		p.retries++
+		p.rto *= 2
+		if p.rto > RelMaxRto {
+			p.rto = RelMaxRto
+		}
+		p.next = now.Add(p.rto)
+		resend = append(resend, p.data)
+	}
+	if len(r.pending) > 0 && r.timer != nil {
+		r.timer.Reset(RelTick)
+	} else {
+		r.timer = nil
+	}
+	r.lock.Unlock()
+	for _, data := range resend {
+		frame := NewFrameMessage()
+		frame.Append(data)
+		if err := r.send(frame); err != nil {
+			r.out.Warn("Reliable.onTimer: %s", err)
+		}
+		frame.Free()
+	}
+}
+
+// OnFrame acknowledges frame with sequence, and returns true if the
+// frame is consumed as acknowledgement or duplicated one. The consumed
+// frame is freed, and a response stops retransmitting its request.
+func (r *Reliable) OnFrame(frame *FrameMessage) bool {
+	if !frame.Decode() {
+		return false
+	}
+	action := frame.Action()
+	seq := frame.CtlSeq()
+	if action == AckResp {
+		r.lock.Lock()
+		delete(r.pending, seq)
+		r.lock.Unlock()
+		frame.Free()
+		return true
+	}
+	if seq == 0 {
+		return false
+	}
+	ack := NewSeqControlFrame(AckResp, seq, 0, nil)
+	if err := r.send(ack); err != nil {
+		r.out.Warn("Reliable.OnFrame: %s", err)
+	}
+	ack.Free()
+
+	now := time.Now().Unix()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if ref := frame.CtlRef(); ref != 0 {
+		delete(r.pending, ref)
+	}
+	if _, ok := r.recvd[seq]; ok {
+		frame.Free()
+		return true
+	}
+	if len(r.recvd) >= 1024 {
+		for k, v := range r.recvd {
+			if now-v > RelWindow {
+				delete(r.recvd, k)
+			}
+		}
+	}
+	r.recvd[seq] = now
+	if action[EthDI-2] == '=' {
+		r.lastReq[action[:EthDI-2]] = seq
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package libol
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReliable_Retransmit(t *testing.T) {
+	toA := make(chan *FrameMessage, 16)
+	toB := make(chan *FrameMessage, 16)
+	a := NewReliable(func(f *FrameMessage) error {
+		toB <- NewFrameMessageFromPool(f.buffer[:HlSize+f.size])
+		return nil
+	}, NewSubLogger("a"))
+	b := NewReliable(func(f *FrameMessage) error {
+		toA <- NewFrameMessageFromPool(f.buffer[:HlSize+f.size])
+		return nil
+	}, NewSubLogger("b"))
+
+	req := a.Wrap(NewControlFrame(LoginReq, []byte("hi")))
+	assert.Equal(t, uint32(1), req.CtlSeq(), "be the same.")
+	// first sending is lost.
+
+	// retransmitted after timeout.
+	frame := <-toB
+	assert.Equal(t, false, b.OnFrame(frame), "be the same.")
+	assert.Equal(t, LoginReq, frame.Action(), "be the same.")
+	assert.Equal(t, "hi", string(frame.params), "be the same.")
+	assert.Equal(t, true, a.OnFrame(<-toA), "be the same.")
+	assert.Equal(t, 0, len(a.pending), "be the same.")
+
+	// duplicated is dropped.
+	dup := NewFrameMessageFromPool(req.buffer[:HlSize+req.size])
+	assert.Equal(t, true, b.OnFrame(dup), "be the same.")
+	assert.Equal(t, true, a.OnFrame(<-toA), "be the same.")
+
+	// response refers to request.
+	assert.Equal(t, true, b.Requested(LoginResp), "be the same.")
+	resp := b.Wrap(NewControlFrame(LoginResp, []byte("okay")))
+	assert.Equal(t, req.CtlSeq(), resp.CtlRef(), "be the same.")
+	assert.Equal(t, false, b.Requested(LoginResp), "be the same.")
+	_ = b.send(resp)
+	m := <-toA
+	assert.Equal(t, false, a.OnFrame(m), "be the same.")
+	assert.Equal(t, LoginResp, m.Action(), "be the same.")
+	assert.Equal(t, "okay", string(m.params), "be the same.")
+
+	// response stops retransmitting request if acknowledgement lost.
+	req = a.Wrap(NewControlFrame(JoinReq, []byte("hi")))
+	assert.Equal(t, false, b.OnFrame(NewFrameMessageFromPool(req.buffer[:HlSize+req.size])), "be the same.")
+	<-toA // acknowledgement lost.
+	resp = b.Wrap(NewControlFrame(JoinResp, []byte("okay")))
+	assert.Equal(t, 1, len(a.pending), "be the same.")
+	assert.Equal(t, false, a.OnFrame(NewFrameMessageFromPool(resp.buffer[:HlSize+resp.size])), "be the same.")
+	a.lock.Lock()
+	assert.Equal(t, 0, len(a.pending), "be the same.")
+	a.lock.Unlock()
+}
+
+// dropRelay forwards datagrams between client and server, and drops the
+// first one of client which has login.
+func dropRelay(t *testing.T, server string) (*net.UDPConn, chan bool) {
+	relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, err := net.Dial("udp", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped := make(chan bool, 1)
+	go func() {
+		drop := true
+		buf := make([]byte, 4096)
+		var client *net.UDPAddr
+		for {
+			n, from, err := relay.ReadFromUDP(buf)
+			if err != nil {
+				_ = up.Close()
+				return
+			}
+			if client == nil {
+				client = from
+				go func() {
+					data := make([]byte, 4096)
+					for {
+						n, err := up.Read(data)
+						if err != nil {
+							return
+						}
+						_, _ = relay.WriteToUDP(data[:n], client)
+					}
+				}()
+			}
+			if drop && bytes.Contains(buf[:n], []byte(LoginReq[:EthDI-1])) {
+				drop = false
+				dropped <- true
+				continue
+			}
+			_, _ = up.Write(buf[:n])
+		}
+	}()
+	return relay, dropped
+}
+
+func TestReliable_LoginLost(t *testing.T) {
+	ln, err := XDPListen("127.0.0.1:0", 16, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	server := NewUdpServer("127.0.0.1:0", &UdpConfig{})
+	logins := make(chan string, 4)
+	go server.Loop(ServerListener{
+		OnClient: func(client SocketClient) error {
+			return nil
+		},
+		ReadAt: func(client SocketClient, frame *FrameMessage) error {
+			action, params := frame.CmdAndParams()
+			if action == LoginReq {
+				logins <- string(params)
+				_ = client.WriteMsg(NewControlFrame(LoginResp, []byte("okay")))
+			}
+			return nil
+		},
+	})
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			server.onClients <- NewUdpClientFromConn(conn, &UdpConfig{})
+		}
+	}()
+	relay, dropped := dropRelay(t, ln.Addr().String())
+	defer relay.Close()
+
+	client := NewUdpClient(relay.LocalAddr().String(), &UdpConfig{Timeout: 5 * time.Second})
+	assert.Nil(t, client.Connect(), "be nil.")
+	defer client.Close()
+	assert.Nil(t, client.WriteMsg(NewControlFrame(LoginReq, []byte("hi"))), "be nil.")
+	for {
+		frame, err := client.ReadMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame.Decode()
+		if frame.Action() == LoginResp {
+			assert.Equal(t, "okay", string(frame.params), "be the same.")
+			break
+		}
+	}
+	assert.Equal(t, 1, len(dropped), "be the same.")
+	assert.Equal(t, "hi", <-logins, "be the same.")
+	assert.Equal(t, 0, len(logins), "be the same.")
+}
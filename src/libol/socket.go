@@ -1,8 +1,12 @@
 package libol
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -60,6 +64,7 @@ type SocketClient interface {
 	ReadMsg() (*FrameMessage, error)
 	UpTime() int64
 	AliveTime() int64
+	IdleTime() int64
 	String() string
 	Terminal()
 	Private() interface{}
@@ -75,32 +80,154 @@ type SocketClient interface {
 	SetListener(listener ClientListener)
 	SetTimeout(v int64)
 	Out() *SubLogger
+	PeerCert() *x509.Certificate
+	SetCompress(name string) error
+	Mtu() int
+	SetMtu(v int)
+	Overhead() int
+	SetAddress(addr string)
+	SetCapability(caps []string)
 }
 
 type StreamSocket struct {
 	message    Messager
+	hand       *Handshake
+	reliable   *Reliable
 	connection net.Conn
 	statistics *SafeStrInt64
 	maxSize    int
 	minSize    int
 	out        *SubLogger
+	addrLock   sync.RWMutex // for address changed by fail over.
 	remoteAddr string
 	localAddr  string
 	address    string
+	activeTime int64        // unix sec of last frame received.
+	mtu        int64        // path MTU measured, and 0 is unknown.
+	caps       atomic.Value // []string negotiated by login.
 }
 
 func (t *StreamSocket) LocalAddr() string {
+	t.addrLock.RLock()
+	defer t.addrLock.RUnlock()
 	return t.localAddr
 }
 
 func (t *StreamSocket) RemoteAddr() string {
+	t.addrLock.RLock()
+	defer t.addrLock.RUnlock()
 	return t.remoteAddr
 }
 
 func (t *StreamSocket) String() string {
+	t.addrLock.RLock()
+	defer t.addrLock.RUnlock()
 	return t.address
 }
 
+// SetAddress changes address of peer, and used by next connecting.
+func (t *StreamSocket) SetAddress(addr string) {
+	t.addrLock.Lock()
+	defer t.addrLock.Unlock()
+	t.address = addr
+	t.remoteAddr = addr
+}
+
+// SetCapability enables capabilities negotiated by login. The session
+// roams only after datagram authenticated, so it is not enabled without aead.
+func (t *StreamSocket) SetCapability(caps []string) {
+	t.caps.Store(caps)
+	if conn, ok := t.connection.(*XDPConn); ok {
+		session := HasCapability(caps, CapSession)
+		if session && (t.message == nil || t.message.Crypt() == nil) {
+			t.out.Warn("StreamSocket.SetCapability: session without aead, and not roams")
+			session = false
+		}
+		conn.SetSession(session)
+	}
+}
+
+func (t *StreamSocket) capable(name string) bool {
+	caps, _ := t.caps.Load().([]string)
+	return HasCapability(caps, name)
+}
+
+// sequenced returns true if control frame is sent reliably. Login is
+// always sequenced by transport, so a login lost is retransmitted before
+// capabilities negotiated, and its response is if the request was.
+func (t *StreamSocket) sequenced(action string) bool {
+	if !t.reliable.Should(action) {
+		return false
+	}
+	return action == LoginReq || t.capable(CapReliable) || t.reliable.Requested(action)
+}
+
+// PeerCert returns certificate of peer verified by TLS.
+func (t *StreamSocket) PeerCert() *x509.Certificate {
+	var state *tls.ConnectionState
+	switch conn := t.connection.(type) {
+	case *tls.Conn:
+		cs := conn.ConnectionState()
+		state = &cs
+	case *wsConn:
+		if req := conn.Request(); req != nil {
+			state = req.TLS
+		}
+	}
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// SetCompress compresses frames by compressor negotiated, and empty name
+// is to disable it.
+func (t *StreamSocket) SetCompress(name string) error {
+	if name == "" {
+		t.message.SetCompress(nil)
+		return nil
+	}
+	c := GetCompressor(name)
+	if c == nil {
+		return NewErr("compress %s notSupport", name)
+	}
+	t.out.Info("StreamSocket.SetCompress %s", name)
+	t.message.SetCompress(c)
+	return nil
+}
+
+// Mtu returns IP MTU of path measured by probes.
+func (t *StreamSocket) Mtu() int {
+	return int(atomic.LoadInt64(&t.mtu))
+}
+
+func (t *StreamSocket) SetMtu(v int) {
+	atomic.StoreInt64(&t.mtu, int64(v))
+}
+
+// Overhead returns bytes around a frame in datagram, as IP, UDP, session
+// id, header and AEAD.
+func (t *StreamSocket) Overhead() int {
+	size := Ipv4Len + UdpLen + HlSize
+	if host, _, err := net.SplitHostPort(t.RemoteAddr()); err == nil && IsIPv6(host) {
+		size = Ipv6Len + UdpLen + HlSize
+	}
+	if t.message != nil {
+		if crypt := t.message.Crypt(); crypt != nil {
+			size += crypt.Overhead()
+		}
+	}
+	if _, ok := t.connection.(*XDPConn); ok {
+		size += XDPSessionLen
+	}
+	return size
+}
+
+// IdleTime returns seconds since last frame received.
+func (t *StreamSocket) IdleTime() int64 {
+	return time.Now().Unix() - atomic.LoadInt64(&t.activeTime)
+}
+
 func (t *StreamSocket) IsOk() bool {
 	return t.connection != nil
 }
@@ -117,15 +244,78 @@ func (t *StreamSocket) WriteMsg(frame *FrameMessage) error {
 	if t.message == nil { // default is stream message
 		t.message = &StreamMessagerImpl{}
 	}
+	if t.reliable != nil && frame.IsControl() && frame.CtlSeq() == 0 &&
+		t.sequenced(frame.Action()) {
+		frame = t.reliable.Wrap(frame)
+		defer frame.Free()
+	}
 	size, err := t.message.Send(t.connection, frame)
 	if err != nil {
 		t.statistics.Add(CsSendError, 1)
 		return err
 	}
 	t.statistics.Add(CsSendOkay, int64(size))
+	if t.hand != nil {
+		t.rekey()
+	}
 	return nil
 }
 
+// sendCtl sends control frame directly.
+func (t *StreamSocket) sendCtl(frame *FrameMessage) error {
+	if !t.IsOk() {
+		return NewErr("%s not okay", t)
+	}
+	_, err := t.message.Send(t.connection, frame)
+	return err
+}
+
+// rekey sends request of handshake if keys expired.
+func (t *StreamSocket) rekey() {
+	frame, err := t.hand.Rekey()
+	if err != nil {
+		t.out.Error("StreamSocket.rekey: %s", err)
+		return
+	}
+	if frame == nil {
+		return
+	}
+	t.out.Info("StreamSocket.rekey: %s", t)
+	if _, err := t.message.Send(t.connection, frame); err != nil {
+		t.out.Error("StreamSocket.rekey: %s", err)
+	}
+}
+
+// onHand processes frame of handshake, and returns true if consumed.
+func (t *StreamSocket) onHand(frame *FrameMessage) (bool, error) {
+	frame.Decode()
+	action, params := frame.CmdAndParams()
+	switch action {
+	case HandReq:
+		if t.hand.client {
+			return true, NewErr("%s: unexpected handshake", t)
+		}
+		resp, update, err := t.hand.OnRequest(params)
+		if err != nil {
+			return true, err
+		}
+		if _, err := t.message.Send(t.connection, resp); err != nil {
+			return true, err
+		}
+		t.out.Info("StreamSocket.onHand: %s", t)
+		return true, update()
+	case HandResp:
+		if err := t.hand.OnResponse(params); err != nil {
+			t.out.Warn("StreamSocket.onHand: %s", err)
+		}
+		return true, nil
+	}
+	if !t.hand.Done() {
+		return true, NewErr("%s: handshake required", t)
+	}
+	return false, nil
+}
+
 func (t *StreamSocket) ReadMsg() (*FrameMessage, error) {
 	if HasLog(LOG) {
 		Log("StreamSocket.ReadMsg: %s", t)
@@ -136,13 +326,27 @@ func (t *StreamSocket) ReadMsg() (*FrameMessage, error) {
 	if t.message == nil { // default is stream message
 		t.message = &StreamMessagerImpl{}
 	}
-	frame, err := t.message.Receive(t.connection, t.maxSize, t.minSize)
-	if err != nil {
-		return nil, err
+	for {
+		frame, err := t.message.Receive(t.connection, t.maxSize, t.minSize)
+		if err != nil {
+			return nil, err
+		}
+		size := len(frame.frame)
+		t.statistics.Add(CsRecvOkay, int64(size))
+		atomic.StoreInt64(&t.activeTime, time.Now().Unix())
+		if t.hand != nil {
+			if ok, err := t.onHand(frame); err != nil {
+				return nil, err
+			} else if ok {
+				frame.Free()
+				continue
+			}
+		}
+		if t.reliable != nil && t.reliable.OnFrame(frame) {
+			continue
+		}
+		return frame, nil
 	}
-	size := len(frame.frame)
-	t.statistics.Add(CsRecvOkay, int64(size))
-	return frame, nil
 }
 
 type SocketClientImpl struct {
@@ -162,6 +366,7 @@ func NewSocketClient(address string, message Messager) *SocketClientImpl {
 			maxSize:    1514,
 			minSize:    15,
 			message:    message,
+			hand:       NewHandshake(message.Crypt(), true),
 			statistics: NewSafeStrInt64(),
 			out:        NewSubLogger(address),
 			remoteAddr: address,
@@ -174,7 +379,7 @@ func NewSocketClient(address string, message Messager) *SocketClientImpl {
 
 func (s *SocketClientImpl) Out() *SubLogger {
 	if s.out == nil {
-		s.out = NewSubLogger(s.address)
+		s.out = NewSubLogger(s.String())
 	}
 	return s.out
 }
@@ -254,18 +459,55 @@ func (s *SocketClientImpl) updateConn(conn net.Conn) {
 	if conn != nil {
 		s.connection = conn
 		s.connectedTime = time.Now().Unix()
+		atomic.StoreInt64(&s.activeTime, s.connectedTime)
+		s.addrLock.Lock()
 		s.localAddr = conn.LocalAddr().String()
 		s.remoteAddr = conn.RemoteAddr().String()
+		s.addrLock.Unlock()
 	} else {
 		if s.connection != nil {
 			_ = s.connection.Close()
 		}
 		s.connection = nil
+		s.addrLock.Lock()
 		s.localAddr = ""
 		s.remoteAddr = ""
+		s.addrLock.Unlock()
 		s.message.Flush()
 	}
-	s.out.Event("SocketClientImpl.updateConn: %s %s", s.localAddr, s.remoteAddr)
+	if s.reliable != nil {
+		s.reliable.Reset()
+	}
+	s.caps.Store([]string(nil))
+	s.out.Event("SocketClientImpl.updateConn: %s %s", s.LocalAddr(), s.RemoteAddr())
+}
+
+// handshake exchanges keys before login, and called by Connect.
+func (s *SocketClientImpl) handshake() error {
+	if s.hand == nil {
+		return nil
+	}
+	s.hand.Reset()
+	frame, err := s.hand.Request()
+	if err != nil {
+		return err
+	}
+	if err := s.WriteMsg(frame); err != nil {
+		return err
+	}
+	conn := s.connection
+	_ = conn.SetReadDeadline(time.Now().Add(HandTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		frame, err := s.message.Receive(conn, MaxFrame, s.minSize)
+		if err != nil {
+			return err
+		}
+		frame.Decode()
+		if action, params := frame.CmdAndParams(); action == HandResp {
+			return s.hand.OnResponse(params)
+		}
+	}
 }
 
 func (s *SocketClientImpl) SetConnection(conn net.Conn) {
@@ -285,6 +527,7 @@ const (
 	SsDrop   = "dropped"
 	SsAccept = "accept"
 	SsClose  = "closed"
+	SsEvict  = "evicted"
 )
 
 type ServerListener struct {
@@ -308,9 +551,9 @@ type SocketServer interface {
 	Address() string
 	Statistics() map[string]int64
 	SetTimeout(v int64)
+	SetKeepalive(interval, deadline int64)
 }
 
-// TODO keepalive to release zombie connections.
 type SocketServerImpl struct {
 	lock       sync.RWMutex
 	statistics *SafeStrInt64
@@ -320,8 +563,11 @@ type SocketServerImpl struct {
 	onClients  chan SocketClient
 	offClients chan SocketClient
 	close      func()
-	timeout    int64 // sec for read and write timeout
-	WrQus      int   // per frames.
+	timeout    int64    // sec for read and write timeout
+	keepalive  int64    // sec to ping clients.
+	deadline   int64    // sec to evict client without any frame received.
+	pinging    sync.Map // clients which ping is being written to.
+	WrQus      int      // per frames.
 	error      error
 }
 
@@ -375,7 +621,7 @@ func (t *SocketServerImpl) doOnClient(call ServerListener, client SocketClient)
 func (t *SocketServerImpl) doOffClient(call ServerListener, client SocketClient) {
 	Info("SocketServerImpl.doOffClient: -%s", client)
 	addr := client.RemoteAddr()
-	if _, ok := t.clients.GetEx(addr); ok {
+	if v, ok := t.clients.GetEx(addr); ok && v == client {
 		Info("SocketServerImpl.doOffClient: close %s", addr)
 		t.statistics.Add(SsClose, 1)
 		if call.OnClose != nil {
@@ -387,15 +633,61 @@ func (t *SocketServerImpl) doOffClient(call ServerListener, client SocketClient)
 	}
 }
 
+// doKeepalive evicts the clients silent past deadline, and pings
+// others so that half-open connections are found by write error.
+func (t *SocketServerImpl) doKeepalive(call ServerListener) {
+	evicted := make([]SocketClient, 0, 32)
+	alive := make([]SocketClient, 0, 32)
+	t.clients.Iter(func(k string, v interface{}) {
+		if client, ok := v.(SocketClient); ok {
+			if t.deadline > 0 && client.IdleTime() > t.deadline {
+				evicted = append(evicted, client)
+			} else {
+				alive = append(alive, client)
+			}
+		}
+	})
+	for _, client := range evicted {
+		Info("SocketServerImpl.doKeepalive: evict %s", client)
+		t.statistics.Add(SsEvict, 1)
+		t.doOffClient(call, client)
+	}
+	// pings out of loop, so that a client blocked on writing never stalls
+	// others, and it is skipped until last ping written.
+	body := fmt.Sprintf(`{"datetime":%d}`, time.Now().UnixNano())
+	for _, client := range alive {
+		if _, ok := t.pinging.LoadOrStore(client, true); ok {
+			continue
+		}
+		client := client
+		Go(func() {
+			defer t.pinging.Delete(client)
+			m := NewControlFrame(PingReq, []byte(body))
+			if err := client.WriteMsg(m); err != nil {
+				Warn("SocketServerImpl.doKeepalive: %s %s", client, err)
+				t.OffClient(client)
+			}
+		})
+	}
+}
+
 func (t *SocketServerImpl) Loop(call ServerListener) {
 	Debug("SocketServerImpl.Loop")
 	defer t.close()
+	var tick <-chan time.Time
+	if t.keepalive > 0 {
+		ticker := time.NewTicker(time.Duration(t.keepalive) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 	for {
 		select {
 		case client := <-t.onClients:
 			t.doOnClient(call, client)
 		case client := <-t.offClients:
 			t.doOffClient(call, client)
+		case <-tick:
+			t.doKeepalive(call)
 		}
 	}
 }
@@ -408,7 +700,9 @@ func (t *SocketServerImpl) Read(client SocketClient, ReadAt ReadClient) {
 		for {
 			select {
 			case frame := <-queue:
-				if err := ReadAt(client, frame); err != nil {
+				err := ReadAt(client, frame)
+				frame.Free()
+				if err != nil {
 					Error("SocketServerImpl.Read: readAt %s", err)
 					return
 				}
@@ -462,6 +756,11 @@ func (t *SocketServerImpl) SetTimeout(v int64) {
 	t.timeout = v
 }
 
+func (t *SocketServerImpl) SetKeepalive(interval, deadline int64) {
+	t.keepalive = interval
+	t.deadline = deadline
+}
+
 // pre-process when accept connection,
 // and allowed accept new connection, will return nil.
 func (t *SocketServerImpl) preAccept(conn net.Conn, err error) error {
@@ -0,0 +1,98 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSocketServer_Keepalive(t *testing.T) {
+	cfg := &TcpConfig{WrQus: 16}
+	server := NewTcpServer("127.0.0.1:0", cfg)
+	server.SetKeepalive(1, 1)
+	closed := make(chan SocketClient, 1)
+	go server.Loop(ServerListener{
+		OnClose: func(client SocketClient) error {
+			closed <- client
+			return nil
+		},
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	peer, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.onClients <- NewTcpClientFromConn(conn, cfg)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not evicted")
+	}
+	assert.Equal(t, int64(1), server.Statistics()[SsEvict], "be the same.")
+	assert.Equal(t, 0, server.TotalClient(), "be the same.")
+}
+
+func TestSocketServer_KeepaliveBlocked(t *testing.T) {
+	cfg := &TcpConfig{WrQus: 16}
+	server := NewTcpServer("127.0.0.1:0", cfg)
+	server.SetKeepalive(1, 3)
+	accepted := make(chan SocketClient, 2)
+	go server.Loop(ServerListener{
+		OnClient: func(client SocketClient) error {
+			accepted <- client
+			return nil
+		},
+	})
+	// nobody reads the pipe, so ping to it is blocked.
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	server.onClients <- NewTcpClientFromConn(conn, cfg)
+	<-accepted
+	time.Sleep(1500 * time.Millisecond)
+	other, _ := net.Pipe()
+	server.onClients <- NewTcpClientFromConn(other, cfg)
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("loop blocked by ping")
+	}
+}
+
+func TestUdpClient_SessionWithoutAead(t *testing.T) {
+	l, err := XDPListen("127.0.0.1:0", 16, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client := NewUdpClient(l.Addr().String(), &UdpConfig{})
+	assert.Nil(t, client.Connect(), "be nil.")
+	defer client.Close()
+	client.SetCapability([]string{CapReliable, CapSession})
+	assert.Nil(t, client.WriteMsg(NewControlFrame(PingReq, []byte("hi"))), "be nil.")
+	s, _ := l.Accept()
+	_ = s.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	_, err = s.Read(buf)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, "", s.(*XDPConn).Session(), "be the same.")
+	from := s.RemoteAddr().String()
+
+	// same client from new address is not roamed, but a new one.
+	conn := client.connection.(*XDPConn)
+	b, _ := net.DialUDP("udp", nil, l.Addr().(*net.UDPAddr))
+	defer b.Close()
+	_, _ = b.Write(append(conn.session, "roam"...))
+	n, _ := s.Read(buf)
+	assert.NotEqual(t, "roam", string(buf[:n]), "be not the same.")
+	assert.Equal(t, from, s.RemoteAddr().String(), "be the same.")
+}
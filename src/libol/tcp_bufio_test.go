@@ -2,12 +2,15 @@ package libol
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"github.com/stretchr/testify/assert"
 	"log"
 	"net"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 type Counter struct {
@@ -107,3 +110,118 @@ func BenchmarkClientAndServer(b *testing.B) {
 	wg.Wait()
 	//fmt.Printf("Total tx: %d, rx: %d\n", c.Tx, c.Rx)
 }
+
+func BenchmarkStreamMessager(b *testing.B) {
+	c, s := net.Pipe()
+	sender := &StreamMessagerImpl{}
+	receiver := &StreamMessagerImpl{}
+	go func() {
+		data := make([]byte, 1500)
+		for i := 0; i < b.N; i++ {
+			frame := NewFrameMessage()
+			frame.Append(data)
+			if _, err := sender.Send(c, frame); err != nil {
+				break
+			}
+			frame.Free()
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frame, err := receiver.Receive(s, MaxBuf, HlSize+EtherLen)
+		if err != nil {
+			b.Fatal(err)
+		}
+		frame.Free()
+	}
+	_ = c.Close()
+}
+
+// recordConn records bytes of every write.
+type recordConn struct {
+	net.Conn
+	lock   sync.Mutex
+	writes [][]byte
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.writes = append(c.writes, append([]byte{}, b...))
+	return len(b), nil
+}
+
+func (c *recordConn) Writes() [][]byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.writes
+}
+
+func TestStreamMessager_Batch(t *testing.T) {
+	conn := &recordConn{}
+	// timer never fires, and flushed by onFlush.
+	sender := &StreamMessagerImpl{batch: 4096, delay: time.Hour}
+	plain := &recordConn{}
+	encoder := &StreamMessagerImpl{}
+	send := func(frame *FrameMessage) {
+		_, err := sender.Send(conn, frame)
+		assert.Equal(t, nil, err, "be the same.")
+		_, _ = encoder.Send(plain, frame)
+	}
+	data := make([]byte, 64)
+	for i := 0; i < 3; i++ {
+		frame := NewFrameMessage()
+		frame.Append(data)
+		send(frame)
+		frame.Free()
+	}
+	assert.Equal(t, 0, len(conn.Writes()), "be the same.")
+	// frames coalesced are flushed after delay.
+	sender.onFlush()
+	writes := conn.Writes()
+	assert.Equal(t, 1, len(writes), "be the same.")
+	assert.Equal(t, bytes.Join(plain.Writes(), nil), writes[0], "be the same.")
+	// control is flushed at once with frames before it.
+	frame := NewFrameMessage()
+	frame.Append(data)
+	send(frame)
+	frame.Free()
+	send(NewControlFrame(PingReq, []byte("hi")))
+	writes = conn.Writes()
+	assert.Equal(t, 2, len(writes), "be the same.")
+	assert.Equal(t, bytes.Join(plain.Writes()[3:], nil), writes[1], "be the same.")
+	// flushed nothing if empty.
+	sender.onFlush()
+	assert.Equal(t, 2, len(conn.Writes()), "be the same.")
+}
+
+func TestStreamMessager_Channel(t *testing.T) {
+	cfg, _ := NewAeadConfig(AlgoChaCha20, make([]byte, AeadKeySize(AlgoChaCha20)))
+	for _, aead := range []bool{false, true} {
+		c, s := net.Pipe()
+		sender := &StreamMessagerImpl{}
+		receiver := &StreamMessagerImpl{}
+		if aead {
+			sender.aead = cfg.NewCrypt()
+			receiver.aead = cfg.NewCrypt()
+		}
+		go func() {
+			for _, ch := range []uint8{0, 3} {
+				frame := NewFrameMessage()
+				frame.Append(make([]byte, 64))
+				frame.SetChannel(ch)
+				_, _ = sender.Send(c, frame)
+				frame.Free()
+			}
+		}()
+		for _, ch := range []uint8{0, 3} {
+			frame, err := receiver.Receive(s, MaxBuf, HlSize)
+			assert.Equal(t, nil, err, "be the same.")
+			assert.Equal(t, ch, frame.Channel(), "be the same.")
+			assert.Equal(t, 64, frame.Size(), "be the same.")
+			frame.Free()
+		}
+		_ = c.Close()
+	}
+}
@@ -10,9 +10,14 @@ import (
 type TcpConfig struct {
 	Tls     *tls.Config
 	Block   kcp.BlockCrypt
+	Aead    *AeadConfig
 	Timeout time.Duration // ns
 	RdQus   int           // per frames
 	WrQus   int           // per frames
+	Batch   int           // bytes to coalesce frames, and 0 is disabled.
+	Delay   time.Duration // ns to flush frames coalesced.
+	Proxy   *Proxy        // dials through it if not nil.
+	Pin     *CertPin      // verifies certificate of server by fingerprint.
 }
 
 // Server Implement
@@ -96,8 +101,11 @@ func NewTcpClient(addr string, cfg *TcpConfig) *TcpClient {
 		tcpCfg: cfg,
 		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
 			block:   cfg.Block,
+			aead:    cfg.Aead.NewCrypt(),
 			timeout: cfg.Timeout,
 			bufSize: cfg.RdQus * MaxFrame,
+			batch:   cfg.Batch,
+			delay:   cfg.Delay,
 		}),
 	}
 	return t
@@ -109,10 +117,14 @@ func NewTcpClientFromConn(conn net.Conn, cfg *TcpConfig) *TcpClient {
 		tcpCfg: cfg,
 		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
 			block:   cfg.Block,
+			aead:    cfg.Aead.NewCrypt(),
 			timeout: cfg.Timeout,
 			bufSize: cfg.RdQus * MaxFrame,
+			batch:   cfg.Batch,
+			delay:   cfg.Delay,
 		}),
 	}
+	t.hand = NewHandshake(t.message.Crypt(), false)
 	t.updateConn(conn)
 	return t
 }
@@ -123,17 +135,30 @@ func (t *TcpClient) Connect() error {
 	}
 	var err error
 	var conn net.Conn
-	if t.tcpCfg.Tls != nil {
-		t.out.Info("TcpClient.Connect: tls://%s", t.address)
-		conn, err = tls.Dial("tcp", t.address, t.tcpCfg.Tls)
+	addr := t.String()
+	tlsCfg := t.tcpCfg.Tls
+	if pin := t.tcpCfg.Pin; pin != nil && tlsCfg != nil {
+		tlsCfg = tlsCfg.Clone()
+		pin.Update(tlsCfg, addr)
+	}
+	if proxy := t.tcpCfg.Proxy; proxy != nil && !proxy.Bypass(addr) {
+		t.out.Info("TcpClient.Connect: %s by %s", addr, proxy)
+		conn, err = proxy.DialTls(addr, tlsCfg)
+	} else if tlsCfg != nil {
+		t.out.Info("TcpClient.Connect: tls://%s", addr)
+		conn, err = tls.Dial("tcp", addr, tlsCfg)
 	} else {
-		t.out.Info("TcpClient.Connect: tcp://%s", t.address)
-		conn, err = net.Dial("tcp", t.address)
+		t.out.Info("TcpClient.Connect: tcp://%s", addr)
+		conn, err = net.Dial("tcp", addr)
 	}
 	if err != nil {
 		return err
 	}
 	t.SetConnection(conn)
+	if err := t.handshake(); err != nil {
+		t.Close()
+		return err
+	}
 	if t.listener.OnConnected != nil {
 		_ = t.listener.OnConnected(t)
 	}
@@ -8,6 +8,7 @@ import (
 
 type UdpConfig struct {
 	Block   kcp.BlockCrypt
+	Aead    *AeadConfig
 	Timeout time.Duration // ns
 	Clients int
 }
@@ -39,7 +40,7 @@ func NewUdpServer(listen string, cfg *UdpConfig) *UdpServer {
 }
 
 func (k *UdpServer) Listen() (err error) {
-	k.listener, err = XDPListen(k.address, k.udpCfg.Clients)
+	k.listener, err = XDPListen(k.address, k.udpCfg.Clients, k.udpCfg.Timeout)
 	if err != nil {
 		k.listener = nil
 		return err
@@ -95,8 +96,10 @@ func NewUdpClient(addr string, cfg *UdpConfig) *UdpClient {
 		SocketClientImpl: NewSocketClient(addr, &PacketMessagerImpl{
 			timeout: cfg.Timeout,
 			block:   cfg.Block,
+			aead:    cfg.Aead.NewCrypt(),
 		}),
 	}
+	c.reliable = NewReliable(c.sendCtl, c.out)
 	return c
 }
 
@@ -109,8 +112,11 @@ func NewUdpClientFromConn(conn net.Conn, cfg *UdpConfig) *UdpClient {
 		SocketClientImpl: NewSocketClient(addr, &PacketMessagerImpl{
 			timeout: cfg.Timeout,
 			block:   cfg.Block,
+			aead:    cfg.Aead.NewCrypt(),
 		}),
 	}
+	c.hand = NewHandshake(c.message.Crypt(), false)
+	c.reliable = NewReliable(c.sendCtl, c.out)
 	c.updateConn(conn)
 	return c
 }
@@ -119,12 +125,17 @@ func (c *UdpClient) Connect() error {
 	if !c.Retry() {
 		return nil
 	}
-	c.out.Info("UdpClient.Connect: udp://%s", c.address)
-	conn, err := net.Dial("udp", c.address)
+	addr := c.String()
+	c.out.Info("UdpClient.Connect: udp://%s", addr)
+	conn, err := XDPDial(addr)
 	if err != nil {
 		return err
 	}
 	c.SetConnection(conn)
+	if err := c.handshake(); err != nil {
+		c.Close()
+		return err
+	}
 	if c.listener.OnConnected != nil {
 		_ = c.listener.OnConnected(c)
 	}
@@ -195,7 +195,10 @@ func PrettyBytes(b int64) string {
 }
 
 func GetIPAddr(addr string) string {
-	return strings.Split(addr, ":")[0]
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
 }
 
 func Wait() {
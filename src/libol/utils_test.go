@@ -93,3 +93,19 @@ func TestScanPure(t *testing.T) {
 	data, err = ScanAnn(buff)
 	assert.Equal(t, string(data), "\t\t\tyou are\t\t\t/", "be the same.")
 }
+
+func TestGetIPAddr(t *testing.T) {
+	assert.Equal(t, "192.168.1.1", GetIPAddr("192.168.1.1:10002"), "be the same.")
+	assert.Equal(t, "192.168.1.1", GetIPAddr("192.168.1.1"), "be the same.")
+	assert.Equal(t, "2001:db8::1", GetIPAddr("[2001:db8::1]:10002"), "be the same.")
+	assert.Equal(t, "2001:db8::1", GetIPAddr("[2001:db8::1]"), "be the same.")
+	assert.Equal(t, "2001:db8::1", GetIPAddr("2001:db8::1"), "be the same.")
+}
+
+func TestIsIPv6(t *testing.T) {
+	assert.Equal(t, false, IsIPv6("192.168.1.1"), "be the same.")
+	assert.Equal(t, false, IsIPv6("192.168.1.0/24"), "be the same.")
+	assert.Equal(t, true, IsIPv6("fd00::1"), "be the same.")
+	assert.Equal(t, true, IsIPv6("fd00::/64"), "be the same.")
+	assert.Equal(t, false, IsIPv6("hi"), "be the same.")
+}
@@ -6,6 +6,43 @@ var (
 	Commit  string
 )
 
+// Protocol is version of frames and controls between point and switch,
+// and increased if it is changed not compatible.
+const (
+	Protocol    = 1
+	MinProtocol = 0 // points older than it are rejected.
+)
+
+// Capabilities exchanged by login, and enabled if both sides have.
+const (
+	CapReliable  = "reliable"  // control frames sequenced and acknowledged.
+	CapSession   = "session"   // session id of UDP datagrams.
+	CapKeepalive = "keepalive" // ping from switch.
+	CapCompress  = "compress"  // payload compressed.
+	CapAead      = "aead"      // frames sealed by AEAD.
+	CapBond      = "bond"      // links of point share one device.
+)
+
+func HasCapability(caps []string, name string) bool {
+	for _, c := range caps {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate returns capabilities of local which remote also has.
+func Negotiate(local, remote []string) []string {
+	caps := make([]string, 0, len(local))
+	for _, c := range local {
+		if HasCapability(remote, c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
 func init() {
 	Info("libol: version is %s", Version)
 	Info("libol: built on %s", Date)
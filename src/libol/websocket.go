@@ -18,7 +18,7 @@ type wsConn struct {
 func (ws *wsConn) RemoteAddr() net.Addr {
 	req := ws.Request()
 	if req == nil {
-		return ws.RemoteAddr()
+		return ws.Conn.RemoteAddr()
 	}
 	addr := req.RemoteAddr
 	if ret, err := net.ResolveTCPAddr("tcp", addr); err == nil {
@@ -32,14 +32,23 @@ type WebCert struct {
 	Crt      string
 	RootCa   string
 	Insecure bool
+	Pin      *CertPin
 }
 
 type WebConfig struct {
 	Cert    *WebCert
+	Tls     *tls.Config // to verify certificate of client.
 	Block   kcp.BlockCrypt
+	Aead    *AeadConfig
 	Timeout time.Duration // ns
 	RdQus   int           // per frames
 	WrQus   int           // per frames
+	Batch   int           // bytes to coalesce frames, and 0 is disabled.
+	Delay   time.Duration // ns to flush frames coalesced.
+	Proxy   *Proxy        // dials through it if not nil.
+	Path    string        // path of websocket, and server is mounted by it.
+	Hosts   []string      // hosts allowed by server, and empty is any.
+	Origins []string      // origins allowed by server, and empty is any.
 }
 
 // Server Implement
@@ -60,14 +69,24 @@ func NewWebServer(listen string, cfg *WebConfig) *WebServer {
 	return t
 }
 
+// Path returns path of websocket if server mounted on other http server.
+func (t *WebServer) Path() string {
+	return t.webCfg.Path
+}
+
 func (t *WebServer) Listen() (err error) {
+	if t.webCfg.Path != "" {
+		Info("WebServer.Listen: mounted on %s", t.webCfg.Path)
+		return nil
+	}
 	if t.webCfg.Cert != nil {
 		Info("WebServer.Listen: wss://%s", t.address)
 	} else {
 		Info("WebServer.Listen: ws://%s", t.address)
 	}
 	t.listener = &http.Server{
-		Addr: t.address,
+		Addr:      t.address,
+		TLSConfig: t.webCfg.Tls,
 	}
 	return nil
 }
@@ -80,23 +99,71 @@ func (t *WebServer) Close() {
 	}
 }
 
+// Handler returns handler of websocket accepting points.
+func (t *WebServer) Handler() http.Handler {
+	return websocket.Server{
+		Handshake: t.handshake,
+		Handler:   t.serve,
+	}
+}
+
+// handshake checks host and origin of request if allowed configured.
+func (t *WebServer) handshake(config *websocket.Config, req *http.Request) error {
+	if hosts := t.webCfg.Hosts; len(hosts) > 0 {
+		host := req.Host
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			host = name
+		}
+		if !hasString(hosts, req.Host) && !hasString(hosts, host) {
+			return NewErr("host %s notAllowed", req.Host)
+		}
+	}
+	origin, err := websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	config.Origin = origin
+	if origins := t.webCfg.Origins; len(origins) > 0 {
+		if origin == nil || !hasString(origins, origin.String()) {
+			return NewErr("origin %s notAllowed", req.Header.Get("Origin"))
+		}
+	}
+	return nil
+}
+
+func (t *WebServer) serve(ws *websocket.Conn) {
+	if t.preAccept(ws, nil) != nil {
+		return
+	}
+	defer ws.Close()
+	// clear deadlines of http server hijacked.
+	_ = ws.SetDeadline(time.Time{})
+	ws.PayloadType = websocket.BinaryFrame
+	wws := &wsConn{ws}
+	client := NewWebClientFromConn(wws, t.webCfg)
+	t.onClients <- client
+	<-client.done
+	Info("WebServer.Accept: %s exit", ws.RemoteAddr())
+}
+
+func hasString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *WebServer) Accept() {
 	Debug("WebServer.Accept")
 
 	_ = t.Listen()
+	if t.webCfg.Path != "" { // accepted by http server mounted on.
+		return
+	}
 	defer t.Close()
-	t.listener.Handler = websocket.Handler(func(ws *websocket.Conn) {
-		if t.preAccept(ws, nil) != nil {
-			return
-		}
-		defer ws.Close()
-		ws.PayloadType = websocket.BinaryFrame
-		wws := &wsConn{ws}
-		client := NewWebClientFromConn(wws, t.webCfg)
-		t.onClients <- client
-		<-client.done
-		Info("WebServer.Accept: %s exit", ws.RemoteAddr())
-	})
+	t.listener.Handler = t.Handler()
 	promise := Promise{
 		First:  2 * time.Second,
 		MinInt: 5 * time.Second,
@@ -134,8 +201,11 @@ func NewWebClient(addr string, cfg *WebConfig) *WebClient {
 		webCfg: cfg,
 		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
 			block:   cfg.Block,
+			aead:    cfg.Aead.NewCrypt(),
 			timeout: cfg.Timeout,
 			bufSize: cfg.RdQus * MaxFrame,
+			batch:   cfg.Batch,
+			delay:   cfg.Delay,
 		}),
 		done: make(chan bool, 2),
 	}
@@ -148,11 +218,15 @@ func NewWebClientFromConn(conn net.Conn, cfg *WebConfig) *WebClient {
 		webCfg: cfg,
 		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
 			block:   cfg.Block,
+			aead:    cfg.Aead.NewCrypt(),
 			timeout: cfg.Timeout,
 			bufSize: cfg.RdQus * MaxFrame,
+			batch:   cfg.Batch,
+			delay:   cfg.Delay,
 		}),
 		done: make(chan bool, 2),
 	}
+	t.hand = NewHandshake(t.message.Crypt(), false)
 	t.updateConn(conn)
 	return t
 }
@@ -176,34 +250,74 @@ func (t *WebClient) Connect() error {
 	}
 	var err error
 	var config *websocket.Config
+	addr := t.String()
 	if t.webCfg.Cert != nil {
-		t.out.Info("WebClient.Connect: wss://%s", t.address)
-		url := "wss://" + t.address
+		t.out.Info("WebClient.Connect: wss://%s%s", addr, t.webCfg.Path)
+		url := "wss://" + addr + t.webCfg.Path
 		if config, err = websocket.NewConfig(url, url); err != nil {
 			return err
 		}
+		ca := t.webCfg.Cert
 		config.TlsConfig = &tls.Config{
-			InsecureSkipVerify: t.webCfg.Cert.Insecure,
-			RootCAs:            t.GetCertPool(t.webCfg.Cert.RootCa),
+			InsecureSkipVerify: ca.Insecure,
+			RootCAs:            t.GetCertPool(ca.RootCa),
+		}
+		if ca.Pin != nil {
+			ca.Pin.Update(config.TlsConfig, addr)
+		}
+		if ca.Crt != "" && ca.Key != "" {
+			cer, err := tls.LoadX509KeyPair(ca.Crt, ca.Key)
+			if err != nil {
+				return err
+			}
+			config.TlsConfig.Certificates = []tls.Certificate{cer}
 		}
 	} else {
-		t.out.Info("WebClient.Connect: ws://%s", t.address)
-		url := "ws://" + t.address
+		t.out.Info("WebClient.Connect: ws://%s%s", addr, t.webCfg.Path)
+		url := "ws://" + addr + t.webCfg.Path
 		if config, err = websocket.NewConfig(url, url); err != nil {
 			return err
 		}
 	}
-	conn, err := websocket.DialConfig(config)
+	var conn *websocket.Conn
+	if proxy := t.webCfg.Proxy; proxy != nil && !proxy.Bypass(addr) {
+		conn, err = t.dialProxy(proxy, addr, config)
+	} else {
+		conn, err = websocket.DialConfig(config)
+	}
 	if err != nil {
 		return err
 	}
 	t.SetConnection(conn)
+	if err := t.handshake(); err != nil {
+		t.Close()
+		return err
+	}
 	if t.listener.OnConnected != nil {
 		_ = t.listener.OnConnected(t)
 	}
 	return nil
 }
 
+// dialProxy opens websocket on connection tunneled by proxy.
+func (t *WebClient) dialProxy(proxy *Proxy, addr string, config *websocket.Config) (*websocket.Conn, error) {
+	t.out.Info("WebClient.Connect: %s by %s", addr, proxy)
+	var tlsCfg *tls.Config
+	if config.Location.Scheme == "wss" {
+		tlsCfg = config.TlsConfig
+	}
+	conn, err := proxy.DialTls(addr, tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
 func (t *WebClient) Close() {
 	t.out.Debug("WebClient.Close: %v", t.IsOk())
 	t.lock.Lock()
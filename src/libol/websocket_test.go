@@ -0,0 +1,40 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebServer_Mount(t *testing.T) {
+	cfg := &WebConfig{WrQus: 16, Path: "/openlan/ws", Hosts: []string{"127.0.0.1"}}
+	server := NewWebServer(cfg.Path, cfg)
+	clients := make(chan SocketClient, 1)
+	go server.Loop(ServerListener{
+		OnClient: func(client SocketClient) error {
+			clients <- client
+			return nil
+		},
+	})
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Path, server.Handler())
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "http://")
+
+	client := NewWebClient(addr, &WebConfig{Path: cfg.Path})
+	assert.Equal(t, nil, client.Connect(), "be the same.")
+	select {
+	case <-clients:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not accepted")
+	}
+	client.Close()
+	// host not allowed.
+	cfg.Hosts = []string{"openlan.net"}
+	client = NewWebClient(addr, &WebConfig{Path: cfg.Path})
+	assert.NotEqual(t, nil, client.Connect(), "not nil.")
+}
@@ -1,82 +1,307 @@
 package libol
 
 import (
+	"crypto/rand"
+	"golang.org/x/net/ipv4"
 	"net"
 	"sync"
 	"time"
 )
 
+// XDPBatch is the max datagrams read or written by one syscall.
+const XDPBatch = 64
+
+// XDPSessionLen is length of session id, which prefixes datagrams from
+// client to switch if session capable. The switch finds the session by it
+// and not by source address, so a client is able to roam. The first byte
+// is XDPSessionMark, and datagrams without it are found by address.
+const (
+	XDPSessionLen  = 8
+	XDPSessionMark = 0x5e
+)
+
+// batchConn reads and writes a batch of datagrams, by recvmmsg and
+// sendmmsg on linux.
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+type xdpPacket struct {
+	buf   *FrameBuffer
+	start int
+	size  int
+	addr  *net.UDPAddr
+}
+
+func (p xdpPacket) Data() []byte {
+	return p.buf[p.start:p.size]
+}
+
 type XDP struct {
 	lock       sync.RWMutex
 	bufSize    int
 	connection *net.UDPConn
+	batch      batchConn
 	address    *net.UDPAddr
 	sessions   *SafeStrMap
 	accept     chan *XDPConn
+	writeQueue chan xdpPacket
+	done       chan struct{}
+	closed     bool
+	timeout    time.Duration // expire the idle sessions.
+	peer       *XDPConn      // dialed connection receives all datagrams.
+}
+
+func newXDP(conn *net.UDPConn, clients int) *XDP {
+	x := &XDP{
+		connection: conn,
+		batch:      newBatchConn(conn),
+		address:    conn.LocalAddr().(*net.UDPAddr),
+		sessions:   NewSafeStrMap(clients * 2), // by address and session id.
+		accept:     make(chan *XDPConn, 2),
+		writeQueue: make(chan xdpPacket, 1024),
+		done:       make(chan struct{}),
+		bufSize:    MaxBuf,
+	}
+	return x
 }
 
-func XDPListen(addr string, clients int) (net.Listener, error) {
+func XDPListen(addr string, clients int, timeout time.Duration) (net.Listener, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
 		return nil, err
 	}
-	x := &XDP{
-		address:  udpAddr,
-		sessions: NewSafeStrMap(clients),
-		accept:   make(chan *XDPConn, 2),
-		bufSize:  MaxBuf,
-	}
 	conn, err := net.ListenUDP("udp", udpAddr)
 	if err != nil {
 		return nil, err
 	}
-	x.connection = conn
+	x := newXDP(conn, clients)
+	x.timeout = timeout
 	Go(x.Loop)
+	Go(x.WriteLoop)
+	if x.timeout > 0 {
+		Go(x.Expire)
+	}
 	return x, nil
 }
 
-func (x *XDP) Recv(udpAddr *net.UDPAddr, data []byte) error {
-	// dispatch to XDPConn and new accept
-	addr := udpAddr.String()
-	if obj, ok := x.sessions.GetEx(addr); ok {
-		conn := obj.(*XDPConn)
-		conn.toQueue(data)
-		return nil
+// XDPDial connects to addr with a new session id enabled by SetSession,
+// and batches reads and writes of the connection as the listener does.
+// The socket is not connected, so that source address follows the route
+// changes.
+func XDPDial(addr string) (net.Conn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
 	}
-	conn := &XDPConn{
-		connection: x.connection,
+	network := "udp4"
+	if udpAddr.IP.To4() == nil {
+		network = "udp6"
+	}
+	session := make([]byte, XDPSessionLen)
+	if _, err := rand.Read(session); err != nil {
+		return nil, err
+	}
+	session[0] = XDPSessionMark
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := setDontFrag(conn, network == "udp6"); err != nil {
+		Warn("XDPDial: %s", err)
+	}
+	x := newXDP(conn, 1)
+	x.peer = x.newConn(udpAddr, func(c *XDPConn) {
+		_ = x.Close()
+	})
+	x.peer.session = session
+	Go(x.Loop)
+	Go(x.WriteLoop)
+	return x.peer, nil
+}
+
+func (x *XDP) newConn(udpAddr *net.UDPAddr, onClose func(conn *XDPConn)) *XDPConn {
+	return &XDPConn{
+		xdp:        x,
 		remoteAddr: udpAddr,
 		localAddr:  x.address,
-		readQueue:  make(chan []byte, 1024),
+		readQueue:  make(chan xdpPacket, 1024),
 		closed:     false,
-		onClose: func(conn *XDPConn) {
-			Info("XDP.Recv: onClose %s", conn)
-			x.sessions.Del(addr)
-		},
+		done:       make(chan struct{}),
+		active:     time.Now(),
+		onClose:    onClose,
+	}
+}
+
+func (x *XDP) Recv(p xdpPacket) error {
+	// dispatch to XDPConn and new accept
+	if x.peer != nil {
+		if !x.peer.isRemote(p.addr) {
+			return NewErr("%s: unknown source", p.addr)
+		}
+		x.peer.toQueue(p)
+		return nil
+	}
+	addr := p.addr.String()
+	id := ""
+	if p.size > XDPSessionLen && p.buf[0] == XDPSessionMark {
+		id = string(p.buf[:XDPSessionLen])
+		p.start = XDPSessionLen
+	}
+	if id != "" {
+		if obj, ok := x.sessions.GetEx(id); ok {
+			obj.(*XDPConn).toQueue(p)
+			return nil
+		}
+	}
+	if obj, ok := x.sessions.GetEx(addr); ok {
+		conn := obj.(*XDPConn)
+		if id != "" && conn.bind(id) {
+			// session enabled by client after login.
+			_ = x.sessions.Set(id, conn)
+		}
+		conn.toQueue(p)
+		return nil
 	}
+	conn := x.newConn(p.addr, func(conn *XDPConn) {
+		Info("XDP.Recv: onClose %s", conn)
+		x.unbind(conn.RemoteAddr().String(), conn)
+		if id := conn.Session(); id != "" {
+			x.unbind(id, conn)
+		}
+	})
+	conn.id = id
 	if err := x.sessions.Set(addr, conn); err != nil {
 		return NewErr("session.Set: %s", err)
 	}
+	if id != "" {
+		_ = x.sessions.Set(id, conn)
+	}
 	x.accept <- conn
-	conn.toQueue(data)
+	conn.toQueue(p)
 	return nil
 }
 
+// unbind deletes key of session if it is still the conn.
+func (x *XDP) unbind(key string, conn *XDPConn) {
+	if obj, ok := x.sessions.GetEx(key); ok && obj == conn {
+		x.sessions.Del(key)
+	}
+}
+
+// roam moves key of address to the conn.
+func (x *XDP) roam(from, to *net.UDPAddr, conn *XDPConn) {
+	x.unbind(from.String(), conn)
+	_ = x.sessions.Mod(to.String(), conn)
+}
+
 // Loop forever
 func (x *XDP) Loop() {
+	bufs := make([]*FrameBuffer, XDPBatch)
+	msgs := make([]ipv4.Message, XDPBatch)
+	for i := range msgs {
+		bufs[i] = GetFrameBuffer()
+		msgs[i].Buffers = [][]byte{bufs[i][:x.bufSize]}
+	}
+	defer func() {
+		for _, buf := range bufs {
+			PutFrameBuffer(buf)
+		}
+	}()
 	for {
-		data := make([]byte, x.bufSize)
-		n, udpAddr, err := x.connection.ReadFromUDP(data)
+		n, err := x.batch.ReadBatch(msgs, 0)
 		if err != nil {
 			Error("XDP.Loop %s", err)
 			break
 		}
-		if err := x.Recv(udpAddr, data[:n]); err != nil {
-			Warn("XDP.Loop: %s", err)
+		for i := 0; i < n; i++ {
+			m := &msgs[i]
+			udpAddr, _ := m.Addr.(*net.UDPAddr)
+			if udpAddr == nil {
+				continue
+			}
+			p := xdpPacket{buf: bufs[i], size: m.N, addr: udpAddr}
+			if err := x.Recv(p); err != nil {
+				Warn("XDP.Loop: %s", err)
+				continue
+			}
+			// buffer is owned by session now.
+			bufs[i] = GetFrameBuffer()
+			m.Buffers[0] = bufs[i][:x.bufSize]
 		}
 	}
 }
 
+// Expire closes the sessions idle for longer than timeout.
+func (x *XDP) Expire() {
+	ticker := time.NewTicker(x.timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.done:
+			return
+		case now := <-ticker.C:
+			idle := make([]*XDPConn, 0, 32)
+			x.sessions.Iter(func(k string, v interface{}) {
+				if conn, ok := v.(*XDPConn); ok && conn.Idle(now) > x.timeout {
+					idle = append(idle, conn)
+				}
+			})
+			for _, conn := range idle {
+				Info("XDP.Expire: %s", conn)
+				_ = conn.Close()
+			}
+		}
+	}
+}
+
+// WriteLoop sends queued datagrams by batch until closed.
+func (x *XDP) WriteLoop() {
+	pkts := make([]xdpPacket, 0, XDPBatch)
+	msgs := make([]ipv4.Message, XDPBatch)
+	for i := range msgs {
+		msgs[i].Buffers = make([][]byte, 1)
+	}
+	for {
+		select {
+		case p := <-x.writeQueue:
+			pkts = append(pkts[:0], p)
+		case <-x.done:
+			return
+		}
+	more:
+		for len(pkts) < XDPBatch {
+			select {
+			case p := <-x.writeQueue:
+				pkts = append(pkts, p)
+			default:
+				break more
+			}
+		}
+		for i, p := range pkts {
+			msgs[i].Buffers[0] = p.Data()
+			msgs[i].Addr = p.addr
+		}
+		x.writeBatch(msgs[:len(pkts)])
+		for _, p := range pkts {
+			PutFrameBuffer(p.buf)
+		}
+	}
+}
+
+func (x *XDP) writeBatch(ms []ipv4.Message) {
+	for len(ms) > 0 {
+		n, err := x.batch.WriteBatch(ms, 0)
+		if err != nil {
+			// drops the one failed, as larger than path MTU.
+			Warn("XDP.writeBatch: %s", err)
+			n++
+		}
+		ms = ms[n:]
+	}
+}
+
 // Accept waits for and returns the next connection to the listener.
 func (x *XDP) Accept() (net.Conn, error) {
 	return <-x.accept, nil
@@ -88,6 +313,11 @@ func (x *XDP) Close() error {
 	x.lock.Lock()
 	defer x.lock.Unlock()
 
+	if x.closed {
+		return nil
+	}
+	x.closed = true
+	close(x.done)
 	_ = x.connection.Close()
 	return nil
 }
@@ -99,25 +329,37 @@ func (x *XDP) Addr() net.Addr {
 
 type XDPConn struct {
 	lock       sync.RWMutex
-	connection *net.UDPConn
+	xdp        *XDP
 	remoteAddr *net.UDPAddr
 	localAddr  *net.UDPAddr
-	readQueue  chan []byte
+	readQueue  chan xdpPacket
+	session    []byte // prefix of datagrams to switch.
+	prefix     bool   // session enabled by capability.
+	id         string // session id of client.
+	from       *net.UDPAddr
 	closed     bool
+	done       chan struct{}
+	active     time.Time
 	readDead   time.Time
 	writeDead  time.Time
 	onClose    func(conn *XDPConn)
 }
 
-func (c *XDPConn) toQueue(b []byte) {
-	c.lock.RLock()
+// toQueue drops the datagram if the session is closed or busy, so
+// that one slow session not blocks the others.
+func (c *XDPConn) toQueue(p xdpPacket) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	if c.closed {
-		c.lock.RUnlock()
+		PutFrameBuffer(p.buf)
 		return
-	} else {
-		c.lock.RUnlock()
 	}
-	c.readQueue <- b
+	c.active = time.Now()
+	select {
+	case c.readQueue <- p:
+	default:
+		PutFrameBuffer(p.buf)
+	}
 }
 
 func (c *XDPConn) Read(b []byte) (n int, err error) {
@@ -143,45 +385,133 @@ func (c *XDPConn) Read(b []byte) (n int, err error) {
 	select {
 	case <-outChan:
 		return 0, NewErr("read timeout")
-	case d := <-c.readQueue:
+	case <-c.done:
+		return 0, NewErr("read on closed")
+	case p := <-c.readQueue:
 		if timeout != nil {
 			timeout.Stop()
 		}
-		return copy(b, d), nil
+		n := copy(b, p.Data())
+		c.lock.Lock()
+		c.from = p.addr
+		c.lock.Unlock()
+		PutFrameBuffer(p.buf)
+		return n, nil
+	}
+}
+
+// bind sets session id of client once, and returns false if it has one.
+func (c *XDPConn) bind(id string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.id != "" {
+		return false
+	}
+	c.id = id
+	return true
+}
+
+// Session returns session id of client, and empty if not enabled.
+func (c *XDPConn) Session() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.id
+}
+
+// Roam migrates the session to source address of the datagram read last,
+// and is called only after the datagram is authenticated.
+func (c *XDPConn) Roam() {
+	c.lock.Lock()
+	if c.closed || c.id == "" || c.from == nil || c.isRemoteLocked(c.from) {
+		c.lock.Unlock()
+		return
 	}
+	x, from, to := c.xdp, c.remoteAddr, c.from
+	Info("XDPConn.Roam: %s roams to %s", from, to)
+	c.remoteAddr = to
+	c.lock.Unlock()
+	x.roam(from, to, c)
+}
+
+// SetSession prefixes datagrams to switch by session id if enabled.
+func (c *XDPConn) SetSession(enable bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.prefix = enable && c.session != nil
 }
 
+// Write copies b into a datagram queued to the batch writer.
 func (c *XDPConn) Write(b []byte) (n int, err error) {
 	c.lock.RLock()
 	if c.closed {
 		c.lock.RUnlock()
 		return 0, NewErr("write to closed")
-	} else {
-		c.lock.RUnlock()
 	}
-	return c.connection.WriteToUDP(b, c.remoteAddr)
+	x := c.xdp
+	p := xdpPacket{addr: c.remoteAddr}
+	var session []byte
+	if c.prefix {
+		session = c.session
+	}
+	c.lock.RUnlock()
+	p.buf = GetFrameBuffer()
+	if len(session)+len(b) > len(p.buf) {
+		PutFrameBuffer(p.buf)
+		return 0, NewErr("write too large %d", len(b))
+	}
+	n = copy(p.buf[:], session)
+	n = copy(p.buf[n:], b)
+	p.size = len(session) + n
+	select {
+	case x.writeQueue <- p:
+		return n, nil
+	case <-x.done:
+		PutFrameBuffer(p.buf)
+		return 0, NewErr("write to closed")
+	}
 }
 
 func (c *XDPConn) Close() error {
 	c.lock.Lock()
-	defer c.lock.Unlock()
 	if c.closed {
+		c.lock.Unlock()
 		return nil
 	}
+	c.xdp = nil
+	c.closed = true
+	close(c.done)
+	c.lock.Unlock()
+
 	if c.onClose != nil {
 		c.onClose(c)
 	}
-	c.connection = nil
-	c.closed = true
-
 	return nil
 }
 
+// Idle returns duration since last datagram received.
+func (c *XDPConn) Idle(now time.Time) time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return now.Sub(c.active)
+}
+
+func (c *XDPConn) isRemote(addr *net.UDPAddr) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.isRemoteLocked(addr)
+}
+
+func (c *XDPConn) isRemoteLocked(addr *net.UDPAddr) bool {
+	return addr != nil && addr.Port == c.remoteAddr.Port && addr.IP.Equal(c.remoteAddr.IP)
+}
+
 func (c *XDPConn) LocalAddr() net.Addr {
 	return c.localAddr
 }
 
 func (c *XDPConn) RemoteAddr() net.Addr {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return c.remoteAddr
 }
 
@@ -208,5 +538,5 @@ func (c *XDPConn) SetWriteDeadline(t time.Time) error {
 }
 
 func (c *XDPConn) String() string {
-	return c.remoteAddr.String()
+	return c.RemoteAddr().String()
 }
@@ -0,0 +1,38 @@
+package libol
+
+import (
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"net"
+	"syscall"
+)
+
+func newBatchConn(conn *net.UDPConn) batchConn {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() != nil {
+		return ipv4.NewPacketConn(conn)
+	}
+	return ipv6.NewPacketConn(conn)
+}
+
+// setDontFrag sets DF of datagrams, so that the ones larger than path MTU
+// are dropped and not fragmented.
+func setDontFrag(conn *net.UDPConn, v6 bool) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	err = raw.Control(func(fd uintptr) {
+		if v6 {
+			opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6,
+				syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO)
+		} else {
+			opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP,
+				syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
@@ -0,0 +1,50 @@
+// +build !linux
+
+package libol
+
+import (
+	"golang.org/x/net/ipv4"
+	"net"
+)
+
+// udpBatch reads and writes one datagram per syscall where
+// recvmmsg and sendmmsg are not available.
+type udpBatch struct {
+	conn *net.UDPConn
+}
+
+func newBatchConn(conn *net.UDPConn) batchConn {
+	return &udpBatch{conn: conn}
+}
+
+func (u *udpBatch) ReadBatch(ms []ipv4.Message, flags int) (int, error) {
+	n, addr, err := u.conn.ReadFromUDP(ms[0].Buffers[0])
+	if err != nil {
+		return 0, err
+	}
+	ms[0].N = n
+	ms[0].Addr = addr
+	return 1, nil
+}
+
+func (u *udpBatch) WriteBatch(ms []ipv4.Message, flags int) (int, error) {
+	for i := range ms {
+		var n int
+		var err error
+		if addr, ok := ms[i].Addr.(*net.UDPAddr); ok {
+			n, err = u.conn.WriteToUDP(ms[i].Buffers[0], addr)
+		} else {
+			n, err = u.conn.Write(ms[i].Buffers[0])
+		}
+		if err != nil {
+			return i, err
+		}
+		ms[i].N = n
+	}
+	return len(ms), nil
+}
+
+// setDontFrag is not supported, and probes of MTU may be fragmented.
+func setDontFrag(conn *net.UDPConn, v6 bool) error {
+	return nil
+}
@@ -0,0 +1,83 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestXDPDialAndAccept(t *testing.T) {
+	l, err := XDPListen("127.0.0.1:0", 16, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c, err := XDPDial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, data := range []string{"hi", "openlan", "xdp"} {
+		_, err := c.Write([]byte(data))
+		assert.Equal(t, nil, err, "be the same.")
+	}
+	s, _ := l.Accept()
+	_ = s.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	for _, data := range []string{"hi", "openlan", "xdp"} {
+		n, err := s.Read(buf)
+		assert.Equal(t, nil, err, "be the same.")
+		assert.Equal(t, data, string(buf[:n]), "be the same.")
+	}
+	_, _ = s.Write([]byte("okay"))
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := c.Read(buf)
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, "okay", string(buf[:n]), "be the same.")
+}
+
+func TestXDPRoamAndExpire(t *testing.T) {
+	l, err := XDPListen("127.0.0.1:0", 16, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	addr := l.Addr().(*net.UDPAddr)
+	session := []byte{XDPSessionMark, '2', '3', '4', '5', '6', '7', '8'}
+	a, _ := net.DialUDP("udp", nil, addr)
+	defer a.Close()
+	b, _ := net.DialUDP("udp", nil, addr)
+	defer b.Close()
+
+	// found by address before session enabled.
+	_, _ = a.Write([]byte("hi"))
+	s, _ := l.Accept()
+	buf := make([]byte, 1024)
+	n, err := s.Read(buf)
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, "hi", string(buf[:n]), "be the same.")
+	assert.Equal(t, a.LocalAddr().String(), s.RemoteAddr().String(), "be the same.")
+	_, _ = a.Write(append(session, "on"...))
+	n, err = s.Read(buf)
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, "on", string(buf[:n]), "be the same.")
+
+	// same session from new address, and not roams until authenticated.
+	_, _ = b.Write(append(session, "roam"...))
+	n, err = s.Read(buf)
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, "roam", string(buf[:n]), "be the same.")
+	assert.Equal(t, a.LocalAddr().String(), s.RemoteAddr().String(), "be the same.")
+	s.(*XDPConn).Roam()
+	assert.Equal(t, b.LocalAddr().String(), s.RemoteAddr().String(), "be the same.")
+	_, _ = s.Write([]byte("okay"))
+	_ = b.SetReadDeadline(time.Now().Add(time.Second))
+	n, err = b.Read(buf)
+	assert.Equal(t, nil, err, "be the same.")
+	assert.Equal(t, "okay", string(buf[:n]), "be the same.")
+
+	// closed after idle.
+	_, err = s.Read(buf)
+	assert.NotEqual(t, nil, err, "be not the same.")
+}
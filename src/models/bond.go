@@ -0,0 +1,65 @@
+package models
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/network"
+	"sync"
+)
+
+// Bond shares device of a point among its links, and frames of the device
+// are sent by link of their flow.
+type Bond struct {
+	ID     string
+	User   string // user@network owns it.
+	Device network.Taper
+	lock   sync.RWMutex
+	links  []libol.SocketClient
+}
+
+func NewBond(id, user string, dev network.Taper) *Bond {
+	return &Bond{
+		ID:     id,
+		User:   user,
+		Device: dev,
+		links:  make([]libol.SocketClient, 0, 4),
+	}
+}
+
+func (b *Bond) Add(client libol.SocketClient) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, c := range b.links {
+		if c == client {
+			return
+		}
+	}
+	b.links = append(b.links, client)
+}
+
+// Del removes link, and returns number of links left.
+func (b *Bond) Del(client libol.SocketClient) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	links := make([]libol.SocketClient, 0, len(b.links))
+	for _, c := range b.links {
+		if c != client {
+			links = append(links, c)
+		}
+	}
+	b.links = links
+	return len(links)
+}
+
+// Link returns link to send frame, and broadcast is by first one so
+// point receives it only once.
+func (b *Bond) Link(frame []byte) libol.SocketClient {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if len(b.links) == 0 {
+		return nil
+	}
+	if len(frame) == 0 || frame[0]&0x01 == 0x01 {
+		return b.links[0]
+	}
+	return b.links[libol.FlowHash(frame)%uint32(len(b.links))]
+}
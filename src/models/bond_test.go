@@ -0,0 +1,32 @@
+package models
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBond_Link(t *testing.T) {
+	b := NewBond("hi", "hi@default", nil)
+	assert.Nil(t, b.Link([]byte{0xff}), "be nil.")
+	c0 := libol.NewTcpClient("a:10002", &libol.TcpConfig{})
+	c1 := libol.NewTcpClient("b:10002", &libol.TcpConfig{})
+	b.Add(c0)
+	b.Add(c1)
+	b.Add(c1)
+	frame := make([]byte, libol.EtherLen)
+	frame[0] = 0xff
+	assert.Equal(t, libol.SocketClient(c0), b.Link(frame), "broadcast by first.")
+	frame[0] = 0x04
+	seen := make(map[libol.SocketClient]bool, 2)
+	for i := 0; i < 32; i++ {
+		frame[1] = byte(i)
+		link := b.Link(frame)
+		assert.Equal(t, link, b.Link(frame), "same flow by same link.")
+		seen[link] = true
+	}
+	assert.Equal(t, 2, len(seen), "be the same.")
+	assert.Equal(t, 1, b.Del(c0), "be the same.")
+	assert.Equal(t, libol.SocketClient(c1), b.Link(frame), "be the same.")
+	assert.Equal(t, 0, b.Del(c1), "be the same.")
+}
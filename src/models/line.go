@@ -1,6 +1,7 @@
 package models
 
 import (
+	"github.com/danieldin95/openlan-go/src/libol"
 	"net"
 	"strconv"
 	"time"
@@ -36,6 +37,13 @@ func (l *Line) String() string {
 	return str
 }
 
+func (l *Line) Family() string {
+	if l.EthType == libol.EthIp6 {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
 func (l *Line) UpTime() int64 {
 	return time.Now().Unix() - l.NewTime
 }
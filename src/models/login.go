@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/danieldin95/openlan-go/src/libol"
+)
+
+const (
+	LoginOkay    = "okay"
+	LoginFailed  = "failed"
+	LoginTooOld  = "tooOld"
+	LoginInvalid = "invalid"
+)
+
+// Login is body of login response, and is also an error if not okay.
+type Login struct {
+	Status     string   `json:"status"`
+	Message    string   `json:"message,omitempty"`
+	Protocol   int      `json:"protocol"`
+	Version    string   `json:"version,omitempty"`
+	Capability []string `json:"capability,omitempty"`
+	Compress   string   `json:"compress,omitempty"` // negotiated.
+	Channel    uint8    `json:"channel,omitempty"`  // network joined.
+}
+
+func NewLogin() *Login {
+	return &Login{
+		Status:   LoginOkay,
+		Protocol: libol.Protocol,
+		Version:  libol.Version,
+	}
+}
+
+func NewLoginErr(status, format string, v ...interface{}) *Login {
+	l := NewLogin()
+	l.Status = status
+	l.Message = fmt.Sprintf(format, v...)
+	return l
+}
+
+// ParseLogin decodes response of login, and plain "okay" is from
+// switch before protocol negotiated.
+func ParseLogin(data []byte) *Login {
+	l := &Login{}
+	if err := json.Unmarshal(data, l); err == nil && l.Status != "" {
+		return l
+	}
+	if len(data) >= 4 && string(data[:4]) == LoginOkay {
+		return &Login{Status: LoginOkay}
+	}
+	return &Login{Status: LoginFailed, Message: string(data)}
+}
+
+func (l *Login) IsOkay() bool {
+	return l.Status == LoginOkay
+}
+
+func (l *Login) Error() string {
+	return fmt.Sprintf("%s: %s", l.Status, l.Message)
+}
+
+func (l *Login) Encode() []byte {
+	data, _ := json.Marshal(l)
+	return data
+}
+
+// EncodeTo encodes response for protocol of point, and point before
+// protocol negotiated only knows plain "okay" or message of error.
+func (l *Login) EncodeTo(protocol int) []byte {
+	if protocol > 0 {
+		return l.Encode()
+	}
+	if l.IsOkay() {
+		return []byte(LoginOkay)
+	}
+	return []byte(l.Message)
+}
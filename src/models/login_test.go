@@ -0,0 +1,36 @@
+package models
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseLogin(t *testing.T) {
+	resp := NewLogin()
+	resp.Compress = "snappy"
+	resp.Capability = []string{libol.CapReliable}
+	l := ParseLogin(resp.Encode())
+	assert.Equal(t, true, l.IsOkay(), "be the same.")
+	assert.Equal(t, libol.Protocol, l.Protocol, "be the same.")
+	assert.Equal(t, "snappy", l.Compress, "be the same.")
+	assert.Equal(t, true, libol.HasCapability(l.Capability, libol.CapReliable), "be the same.")
+
+	l = ParseLogin(NewLoginErr(LoginTooOld, "Protocol %d older than %d.", 0, 1).Encode())
+	assert.Equal(t, false, l.IsOkay(), "be the same.")
+	assert.Equal(t, LoginTooOld, l.Status, "be the same.")
+	assert.Equal(t, "Protocol 0 older than 1.", l.Message, "be the same.")
+
+	// switch before protocol negotiated.
+	l = ParseLogin([]byte("okay"))
+	assert.Equal(t, true, l.IsOkay(), "be the same.")
+	assert.Equal(t, 0, l.Protocol, "be the same.")
+	l = ParseLogin([]byte("Auth failed."))
+	assert.Equal(t, LoginFailed, l.Status, "be the same.")
+	assert.Equal(t, "Auth failed.", l.Message, "be the same.")
+
+	// point before protocol negotiated.
+	assert.Equal(t, "okay", string(NewLogin().EncodeTo(0)), "be the same.")
+	l = ParseLogin(NewLoginErr(LoginFailed, "Auth failed.").EncodeTo(0))
+	assert.Equal(t, "Auth failed.", l.Message, "be the same.")
+}
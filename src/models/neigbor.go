@@ -35,6 +35,13 @@ func NewNeighbor(hwAddr net.HardwareAddr, ipAddr net.IP, client libol.SocketClie
 	return
 }
 
+func (e *Neighbor) Family() string {
+	if e.IpAddr.To4() == nil {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
 func (e *Neighbor) UpTime() int64 {
 	return time.Now().Unix() - e.HitTime
 }
@@ -60,4 +60,9 @@ func TestNetworkEqual(t *testing.T) {
 	assert.Equal(t, true, NetworkEqual(o, n), "be the same.")
 	o.IfAddr = "255.255.255.0"
 	assert.Equal(t, false, NetworkEqual(n, o), "be the same.")
+	o.IfAddr = "192.168.1.1"
+	o.IfAddr6 = "fd00::2/64"
+	assert.Equal(t, false, NetworkEqual(n, o), "be the same.")
+	n.IfAddr6 = "fd00::2/64"
+	assert.Equal(t, true, NetworkEqual(n, o), "be the same.")
 }
@@ -31,6 +31,8 @@ type Network struct {
 	IpStart string   `json:"ipStart"`
 	IpEnd   string   `json:"ipEnd"`
 	Netmask string   `json:"netmask"`
+	IfAddr6 string   `json:"ifAddr6,omitempty"` // with prefix length.
+	Prefix6 string   `json:"prefix6,omitempty"`
 	Routes  []*Route `json:"routes"`
 }
 
@@ -56,8 +58,8 @@ func NewNetwork(name string, ifAddr string) (this *Network) {
 }
 
 func (u *Network) String() string {
-	return fmt.Sprintf("%s, %s, %s, %s, %s, %s",
-		u.Name, u.IfAddr, u.IpStart, u.IpEnd, u.Netmask, u.Routes)
+	return fmt.Sprintf("%s, %s, %s, %s, %s, %s, %s, %s",
+		u.Name, u.IfAddr, u.IpStart, u.IpEnd, u.Netmask, u.IfAddr6, u.Prefix6, u.Routes)
 }
 
 func (u *Network) ParseIP(s string) {
@@ -70,6 +72,8 @@ func NetworkEqual(o *Network, n *Network) bool {
 		return false
 	} else if o.IfAddr != n.IfAddr || o.Netmask != n.Netmask {
 		return false
+	} else if o.IfAddr6 != n.IfAddr6 {
+		return false
 	} else {
 		ors := make([]string, 0, 32)
 		nrs := make([]string, 0, 32)
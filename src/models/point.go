@@ -3,20 +3,26 @@ package models
 import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/network"
+	"sync"
 )
 
 type Point struct {
-	UUID    string             `json:"uuid"`
-	Alias   string             `json:"alias"`
-	Network string             `json:"network"`
-	User    string             `json:"user"`
-	Server  string             `json:"server"`
-	Uptime  int64              `json:"uptime"`
-	Status  string             `json:"status"`
-	IfName  string             `json:"device"`
-	Client  libol.SocketClient `json:"-"`
-	Device  network.Taper      `json:"-"`
-	System  string             `json:"system"`
+	UUID       string             `json:"uuid"`
+	Alias      string             `json:"alias"`
+	Network    string             `json:"network"`
+	User       string             `json:"user"`
+	Server     string             `json:"server"`
+	Uptime     int64              `json:"uptime"`
+	Status     string             `json:"status"`
+	IfName     string             `json:"device"`
+	Client     libol.SocketClient `json:"-"`
+	Device     network.Taper      `json:"-"`
+	System     string             `json:"system"`
+	Protocol   int                `json:"protocol"`
+	Capability []string           `json:"capability,omitempty"`
+	Bond       string             `json:"bond,omitempty"`
+	lock       sync.RWMutex
+	joined     map[uint8]network.Taper // devices of networks joined by channel.
 }
 
 func NewPoint(c libol.SocketClient, d network.Taper) (w *Point) {
@@ -41,14 +47,51 @@ func (p *Point) Update() *Point {
 	return p
 }
 
+// Join saves device of network joined by channel, and returns older.
+func (p *Point) Join(channel uint8, dev network.Taper) network.Taper {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.joined == nil {
+		p.joined = make(map[uint8]network.Taper, 4)
+	}
+	older := p.joined[channel]
+	p.joined[channel] = dev
+	return older
+}
+
+// Joined returns device of channel, and channel 0 is the network logged in.
+func (p *Point) Joined(channel uint8) network.Taper {
+	if channel == 0 {
+		return p.Device
+	}
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.joined[channel]
+}
+
+// Leave removes all networks joined, and returns their devices.
+func (p *Point) Leave() []network.Taper {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	devs := make([]network.Taper, 0, len(p.joined))
+	for _, dev := range p.joined {
+		devs = append(devs, dev)
+	}
+	p.joined = nil
+	return devs
+}
+
 func (p *Point) SetUser(user *User) {
 	p.User = user.Name
 	p.UUID = user.UUID
-	if len(p.UUID) > 13 {
-		// too long and using short uuid.
+	if len(p.UUID) > 13 && user.Bond == "" {
+		// too long and using short uuid, but links of bond keep suffix.
 		p.UUID = p.UUID[:13]
 	}
+	p.Bond = user.Bond
 	p.Network = user.Network
 	p.System = user.System
 	p.Alias = user.Alias
+	p.Protocol = user.Protocol
+	p.Capability = user.Capability
 }
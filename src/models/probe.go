@@ -0,0 +1,10 @@
+package models
+
+// SwitchProbe is latency of a switch probed by point.
+type SwitchProbe struct {
+	Address string `json:"address"`
+	Latency int64  `json:"latency"`          // ms, and -1 is unreachable.
+	Better  int    `json:"better,omitempty"` // times better than active in a row.
+	Active  bool   `json:"active,omitempty"`
+	ProbeAt int64  `json:"probeAt"`
+}
@@ -9,19 +9,22 @@ func NewPointSchema(p *Point) schema.Point {
 	client, dev := p.Client, p.Device
 	sts := client.Statistics()
 	return schema.Point{
-		Uptime:    p.Uptime,
-		UUID:      p.UUID,
-		Alias:     p.Alias,
-		User:      p.User,
-		Address:   client.String(),
-		Device:    dev.Name(),
-		RxBytes:   sts[libol.CsRecvOkay],
-		TxBytes:   sts[libol.CsSendOkay],
-		ErrPkt:    sts[libol.CsSendError],
-		State:     client.Status().String(),
-		Network:   p.Network,
-		AliveTime: client.AliveTime(),
-		System:    p.System,
+		Uptime:     p.Uptime,
+		UUID:       p.UUID,
+		Alias:      p.Alias,
+		User:       p.User,
+		Address:    client.String(),
+		Device:     dev.Name(),
+		RxBytes:    sts[libol.CsRecvOkay],
+		TxBytes:    sts[libol.CsSendOkay],
+		ErrPkt:     sts[libol.CsSendError],
+		State:      client.Status().String(),
+		Network:    p.Network,
+		AliveTime:  client.AliveTime(),
+		System:     p.System,
+		Protocol:   p.Protocol,
+		Capability: p.Capability,
+		Mtu:        client.Mtu(),
 	}
 }
 
@@ -48,6 +51,7 @@ func NewNeighborSchema(n *Neighbor) schema.Neighbor {
 		Uptime:  n.UpTime(),
 		HwAddr:  n.HwAddr.String(),
 		IpAddr:  n.IpAddr.String(),
+		Family:  n.Family(),
 		Client:  n.Client,
 		Network: n.Network,
 		Device:  n.Device,
@@ -59,6 +63,7 @@ func NewOnLineSchema(l *Line) schema.OnLine {
 		HitTime:    l.LastTime(),
 		UpTime:     l.UpTime(),
 		EthType:    l.EthType,
+		Family:     l.Family(),
 		IpSource:   l.IpSource.String(),
 		IpDest:     l.IpDest.String(),
 		IpProto:    libol.IpProto2Str(l.IpProtocol),
@@ -92,6 +97,8 @@ func NewNetworkSchema(n *Network) schema.Network {
 		IpStart: n.IpStart,
 		IpEnd:   n.IpEnd,
 		Netmask: n.Netmask,
+		IfAddr6: n.IfAddr6,
+		Prefix6: n.Prefix6,
 		Routes:  make([]schema.PrefixRoute, 0, 32),
 	}
 	for _, route := range n.Routes {
@@ -7,13 +7,18 @@ import (
 )
 
 type User struct {
-	Alias    string `json:"alias"`
-	Name     string `json:"name"`
-	Network  string `json:"network"`
-	Token    string `json:"token"`
-	Password string `json:"password"`
-	UUID     string `json:"uuid"`
-	System   string `json:"system"`
+	Alias      string   `json:"alias"`
+	Name       string   `json:"name"`
+	Network    string   `json:"network"`
+	Token      string   `json:"token"`
+	Password   string   `json:"password"`
+	UUID       string   `json:"uuid"`
+	System     string   `json:"system"`
+	Compress   []string `json:"compress,omitempty"` // algorithms supported.
+	Protocol   int      `json:"protocol,omitempty"`
+	Capability []string `json:"capability,omitempty"`
+	Channel    uint8    `json:"channel,omitempty"` // to join network.
+	Bond       string   `json:"bond,omitempty"`    // uuid of point which links bonded to.
 }
 
 func NewUser(name, network, password string) *User {
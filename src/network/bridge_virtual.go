@@ -95,8 +95,9 @@ func (b *VirtualBridge) AddSlave(name string) error {
 	b.lock.Unlock()
 	b.out.Info("VirtualBridge.AddSlave: %s", name)
 	libol.Go(func() {
+		// frame is copied by ports, so reuse buffer to receive.
+		data := make([]byte, b.ifMtu)
 		for {
-			data := make([]byte, b.ifMtu)
 			n, err := tap.Recv(data)
 			if err != nil || n == 0 {
 				break
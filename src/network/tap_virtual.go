@@ -5,12 +5,36 @@ import (
 	"sync"
 )
 
+// tapFrame is a copy of frame in queue, and its buffer is from pool.
+type tapFrame struct {
+	pool *libol.FrameBuffer
+	data []byte
+}
+
+func newTapFrame(p []byte) tapFrame {
+	if len(p) > libol.HlSize+libol.MaxBuf {
+		data := make([]byte, len(p))
+		copy(data, p)
+		return tapFrame{data: data}
+	}
+	pool := libol.GetFrameBuffer()
+	n := copy(pool[:], p)
+	return tapFrame{pool: pool, data: pool[:n]}
+}
+
+// copyTo copies frame into p, and returns buffer to pool.
+func (f tapFrame) copyTo(p []byte) int {
+	n := copy(p, f.data)
+	libol.PutFrameBuffer(f.pool)
+	return n
+}
+
 type VirtualTap struct {
 	lock   sync.RWMutex
 	kernC  int
-	kernQ  chan []byte
+	kernQ  chan tapFrame
 	virtC  int
-	virtQ  chan []byte
+	virtQ  chan tapFrame
 	master Bridger
 	tenant string
 	flags  uint
@@ -84,7 +108,7 @@ func (t *VirtualTap) Write(p []byte) (int, error) {
 		return 0, nil
 	}
 	t.virtC++
-	t.virtQ <- p
+	t.virtQ <- newTapFrame(p)
 	return len(p), nil
 }
 
@@ -99,7 +123,7 @@ func (t *VirtualTap) Read(p []byte) (int, error) {
 	t.lock.Lock()
 	t.kernC--
 	t.lock.Unlock()
-	return copy(p, data), nil
+	return data.copyTo(p), nil
 }
 
 func (t *VirtualTap) Recv(p []byte) (int, error) {
@@ -113,7 +137,7 @@ func (t *VirtualTap) Recv(p []byte) (int, error) {
 	t.lock.Lock()
 	t.virtC--
 	t.lock.Unlock()
-	return copy(p, data), nil
+	return data.copyTo(p), nil
 }
 
 func (t *VirtualTap) Send(p []byte) (int, error) {
@@ -131,7 +155,7 @@ func (t *VirtualTap) Send(p []byte) (int, error) {
 		return 0, nil
 	}
 	t.kernC++
-	t.kernQ <- p
+	t.kernQ <- newTapFrame(p)
 	return len(p), nil
 }
 
@@ -172,9 +196,9 @@ func (t *VirtualTap) Up() {
 	defer t.lock.Unlock()
 	if !t.hasFlags(UsUp) {
 		t.kernC = 0
-		t.kernQ = make(chan []byte, t.cfg.KernBuf)
+		t.kernQ = make(chan tapFrame, t.cfg.KernBuf)
 		t.virtC = 0
-		t.virtQ = make(chan []byte, t.cfg.VirtBuf)
+		t.virtQ = make(chan tapFrame, t.cfg.VirtBuf)
 		t.setFlags(UsUp)
 	}
 }
@@ -0,0 +1,120 @@
+package olap
+
+import (
+	"github.com/danieldin95/openlan-go/src/cli/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"sync/atomic"
+	"time"
+)
+
+// bondLink is link of bond to a switch.
+type bondLink interface {
+	Alive() bool
+	Latency() int64
+	Switch() string
+	Bonded() bool
+	Write(frame *libol.FrameMessage) error
+}
+
+// Bonder spreads frames across links, and the first link is primary.
+type Bonder struct {
+	mode    string
+	latency int64
+	links   []bondLink
+	active  atomic.Value // []bondLink healthy.
+	done    chan bool
+	ticker  *time.Ticker
+	out     *libol.SubLogger
+}
+
+func NewBonder(c *config.Bond, links []bondLink) *Bonder {
+	b := &Bonder{
+		mode:    c.Mode,
+		latency: int64(c.Latency),
+		links:   links,
+		done:    make(chan bool, 2),
+		ticker:  time.NewTicker(2 * time.Second),
+		out:     libol.NewSubLogger("bond"),
+	}
+	b.active.Store(links[:1])
+	return b
+}
+
+// check selects links alive and not slower than latency, and keeps only
+// first one in backup mode.
+func (b *Bonder) check() {
+	active := make([]bondLink, 0, len(b.links))
+	for _, link := range b.links {
+		if !link.Alive() {
+			continue
+		}
+		if b.latency > 0 && link.Latency() > b.latency {
+			continue
+		}
+		active = append(active, link)
+		if b.mode == "backup" {
+			break
+		}
+	}
+	if len(active) == 0 {
+		active = append(active, b.links[0])
+	}
+	older := b.Active()
+	if len(older) != len(active) || older[0] != active[0] {
+		b.out.Info("Bonder.check: %d links active by %s", len(active), active[0].Switch())
+	}
+	b.active.Store(active)
+}
+
+func (b *Bonder) Active() []bondLink {
+	return b.active.Load().([]bondLink)
+}
+
+// Write sends frame by link of its flow, and broadcast is by first link.
+func (b *Bonder) Write(frame *libol.FrameMessage) error {
+	active := b.Active()
+	link := active[0]
+	if len(active) > 1 {
+		data := frame.Frame()[:frame.Size()]
+		if len(data) > 0 && data[0]&0x01 == 0 {
+			link = active[libol.FlowHash(data)%uint32(len(active))]
+		}
+	}
+	return link.Write(frame)
+}
+
+// ReadAt returns reader of link, and drops broadcast of device not from
+// first link if switch floods it to all links.
+func (b *Bonder) ReadAt(link bondLink, next func(*libol.FrameMessage) error) func(*libol.FrameMessage) error {
+	return func(frame *libol.FrameMessage) error {
+		data := frame.Frame()[:frame.Size()]
+		if frame.Channel() == 0 && len(data) > 0 && data[0]&0x01 == 0x01 &&
+			b.Active()[0] != link && !link.Bonded() {
+			frame.Free()
+			return nil
+		}
+		return next(frame)
+	}
+}
+
+func (b *Bonder) Loop() {
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-b.ticker.C:
+			b.check()
+		}
+	}
+}
+
+func (b *Bonder) Start() {
+	b.out.Info("Bonder.Start: %s", b.mode)
+	libol.Go(b.Loop)
+}
+
+func (b *Bonder) Stop() {
+	b.out.Info("Bonder.Stop")
+	b.ticker.Stop()
+	b.done <- true
+}
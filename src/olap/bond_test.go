@@ -0,0 +1,179 @@
+package olap
+
+import (
+	"github.com/danieldin95/openlan-go/src/cli/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeLink struct {
+	name    string
+	alive   bool
+	latency int64
+	bonded  bool
+	frames  int
+}
+
+func (l *fakeLink) Alive() bool {
+	return l.alive
+}
+
+func (l *fakeLink) Latency() int64 {
+	return l.latency
+}
+
+func (l *fakeLink) Switch() string {
+	return l.name
+}
+
+func (l *fakeLink) Bonded() bool {
+	return l.bonded
+}
+
+func (l *fakeLink) Write(frame *libol.FrameMessage) error {
+	l.frames++
+	return nil
+}
+
+func newFakeLinks(alive []bool, latency []int64) []bondLink {
+	links := make([]bondLink, 0, len(alive))
+	for i := range alive {
+		links = append(links, &fakeLink{
+			name:    string(rune('a' + i)),
+			alive:   alive[i],
+			latency: latency[i],
+		})
+	}
+	return links
+}
+
+// newEthFrame returns IPv4 UDP frame to destination by source port.
+func newEthFrame(dst byte, port byte) *libol.FrameMessage {
+	data := make([]byte, libol.HlSize+libol.EtherLen+libol.Ipv4Len+8)
+	eth := data[libol.HlSize:]
+	for i := 0; i < 6; i++ {
+		eth[i] = dst
+		eth[6+i] = 0x02
+	}
+	eth[12], eth[13] = 0x08, 0x00
+	ip := eth[libol.EtherLen:]
+	ip[0] = 0x45
+	ip[9] = 17
+	copy(ip[12:20], []byte{192, 168, 1, 1, 192, 168, 1, 2})
+	ip[libol.Ipv4Len+1] = port
+	ip[libol.Ipv4Len+3] = 53
+	return libol.NewFrameMessageFromBytes(data)
+}
+
+func TestBonder_Check(t *testing.T) {
+	cases := []struct {
+		mode    string
+		limit   int
+		alive   []bool
+		latency []int64
+		active  []string
+	}{
+		{"hash", 0, []bool{true, true, true}, []int64{10, 20, 30}, []string{"a", "b", "c"}},
+		{"hash", 0, []bool{false, true, true}, []int64{10, 20, 30}, []string{"b", "c"}},
+		{"hash", 25, []bool{true, true, true}, []int64{10, 20, 30}, []string{"a", "b"}},
+		{"hash", 0, []bool{false, false, false}, []int64{10, 20, 30}, []string{"a"}},
+		{"backup", 0, []bool{true, true, true}, []int64{10, 20, 30}, []string{"a"}},
+		{"backup", 0, []bool{false, true, true}, []int64{10, 20, 30}, []string{"b"}},
+		{"backup", 15, []bool{false, true, true}, []int64{10, 20, 30}, []string{"a"}},
+	}
+	for i, c := range cases {
+		links := newFakeLinks(c.alive, c.latency)
+		b := NewBonder(&config.Bond{Mode: c.mode, Latency: c.limit}, links)
+		b.ticker.Stop()
+		b.check()
+		names := make([]string, 0, len(links))
+		for _, link := range b.Active() {
+			names = append(names, link.Switch())
+		}
+		assert.Equal(t, c.active, names, "case %d be the same.", i)
+	}
+}
+
+func TestBonder_Write(t *testing.T) {
+	cases := []struct {
+		mode  string
+		alive []bool
+		dst   byte
+	}{
+		{"hash", []bool{true, true}, 0x04},
+		{"hash", []bool{true, true}, 0xff},
+		{"hash", []bool{false, true}, 0x04},
+		{"backup", []bool{true, true}, 0x04},
+		{"backup", []bool{false, true}, 0x04},
+	}
+	for i, c := range cases {
+		links := newFakeLinks(c.alive, []int64{0, 0})
+		b := NewBonder(&config.Bond{Mode: c.mode}, links)
+		b.ticker.Stop()
+		b.check()
+		active := b.Active()
+		expected := make(map[bondLink]int, len(links))
+		for port := byte(1); port <= 32; port++ {
+			frame := newEthFrame(c.dst, port)
+			link := active[0]
+			if c.dst&0x01 == 0 && len(active) > 1 {
+				data := frame.Frame()[:frame.Size()]
+				link = active[libol.FlowHash(data)%uint32(len(active))]
+			}
+			expected[link]++
+			// same flow is always by same link.
+			assert.Nil(t, b.Write(frame), "be nil.")
+			assert.Nil(t, b.Write(newEthFrame(c.dst, port)), "be nil.")
+		}
+		for _, link := range links {
+			assert.Equal(t, 2*expected[link], link.(*fakeLink).frames, "case %d be the same.", i)
+		}
+		if c.mode == "backup" || c.dst == 0xff {
+			assert.Equal(t, 64, active[0].(*fakeLink).frames, "case %d be the same.", i)
+		} else if len(active) > 1 {
+			for _, link := range active {
+				assert.NotEqual(t, 0, link.(*fakeLink).frames, "case %d spread.", i)
+			}
+		}
+	}
+}
+
+func TestBonder_ReadAt(t *testing.T) {
+	cases := []struct {
+		mode    string
+		link    int
+		bonded  bool
+		dst     byte
+		channel uint8
+		passed  bool
+	}{
+		{"hash", 0, false, 0xff, 0, true},
+		{"hash", 1, false, 0xff, 0, false},
+		{"hash", 1, false, 0x04, 0, true},
+		{"hash", 1, false, 0xff, 1, true},
+		{"hash", 1, true, 0xff, 0, true},
+		{"backup", 0, false, 0xff, 0, true},
+		{"backup", 1, false, 0xff, 0, false},
+		{"backup", 1, false, 0x04, 0, true},
+		{"backup", 1, true, 0xff, 0, true},
+	}
+	for i, c := range cases {
+		links := newFakeLinks([]bool{true, true}, []int64{0, 0})
+		for _, link := range links {
+			link.(*fakeLink).bonded = c.bonded
+		}
+		b := NewBonder(&config.Bond{Mode: c.mode}, links)
+		b.ticker.Stop()
+		b.check()
+		passed := false
+		read := b.ReadAt(links[c.link], func(frame *libol.FrameMessage) error {
+			passed = true
+			return nil
+		})
+		frame := newEthFrame(c.dst, 1)
+		frame.SetChannel(c.channel)
+		assert.Nil(t, read(frame), "be nil.")
+		assert.Equal(t, c.passed, passed, "case %d be the same.", i)
+	}
+}
@@ -85,6 +85,22 @@ func (h *Http) LoadRouter() {
 			ResponseJson(w, h.pointer.UUID())
 		}
 	})
+	router.HandleFunc("/current/switch", func(w http.ResponseWriter, r *http.Request) {
+		format := GetQueryOne(r, "format")
+		if format == "yaml" {
+			ResponseYaml(w, h.pointer.Addr())
+		} else {
+			ResponseJson(w, h.pointer.Addr())
+		}
+	})
+	router.HandleFunc("/current/probe", func(w http.ResponseWriter, r *http.Request) {
+		format := GetQueryOne(r, "format")
+		if format == "yaml" {
+			ResponseYaml(w, h.pointer.Probes())
+		} else {
+			ResponseJson(w, h.pointer.Probes())
+		}
+	})
 	router.HandleFunc("/current/config", func(w http.ResponseWriter, r *http.Request) {
 		format := GetQueryOne(r, "format")
 		if format == "yaml" {
@@ -1,8 +1,13 @@
 package http
 
-import "github.com/danieldin95/openlan-go/src/cli/config"
+import (
+	"github.com/danieldin95/openlan-go/src/cli/config"
+	"github.com/danieldin95/openlan-go/src/models"
+)
 
 type Pointer interface {
 	UUID() string
+	Addr() string
+	Probes() []models.SwitchProbe
 	Config() *config.Point
 }
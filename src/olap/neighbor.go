@@ -1,7 +1,6 @@
 package olap
 
 import (
-	"encoding/binary"
 	"github.com/danieldin95/openlan-go/src/libol"
 	"sync"
 	"time"
@@ -21,7 +20,7 @@ type Neighbor struct {
 
 type Neighbors struct {
 	lock      sync.RWMutex
-	neighbors map[uint32]*Neighbor
+	neighbors map[string]*Neighbor
 	done      chan bool
 	ticker    *time.Ticker
 	timeout   int64
@@ -32,7 +31,7 @@ type Neighbors struct {
 func (n *Neighbors) Expire() {
 	n.lock.Lock()
 	defer n.lock.Unlock()
-	deletes := make([]uint32, 0, 1024)
+	deletes := make([]string, 0, 1024)
 	//collect need deleted.
 	for index, learn := range n.neighbors {
 		now := time.Now().Unix()
@@ -53,7 +52,7 @@ func (n *Neighbors) Expire() {
 func (n *Neighbors) Interval() {
 	n.lock.Lock()
 	defer n.lock.Unlock()
-	intervals := make([]uint32, 0, 1024)
+	intervals := make([]string, 0, 1024)
 	//collect need keepalive.
 	for index, learn := range n.neighbors {
 		now := time.Now().Unix()
@@ -96,7 +95,7 @@ func (n *Neighbors) Add(h *Neighbor) {
 	}
 	n.lock.Lock()
 	defer n.lock.Unlock()
-	k := binary.BigEndian.Uint32(h.IpAddr)
+	k := string(h.IpAddr)
 	if l, ok := n.neighbors[k]; ok {
 		l.Uptime = h.Uptime
 		copy(l.HwAddr[:6], h.HwAddr[:6])
@@ -105,15 +104,15 @@ func (n *Neighbors) Add(h *Neighbor) {
 			Uptime:  h.Uptime,
 			NewTime: h.NewTime,
 			HwAddr:  make([]byte, 6),
-			IpAddr:  make([]byte, 4),
+			IpAddr:  make([]byte, len(h.IpAddr)),
 		}
-		copy(l.IpAddr[:4], h.IpAddr[:4])
+		copy(l.IpAddr, h.IpAddr)
 		copy(l.HwAddr[:6], h.HwAddr[:6])
 		n.neighbors[k] = l
 	}
 }
 
-func (n *Neighbors) Get(d uint32) *Neighbor {
+func (n *Neighbors) Get(d string) *Neighbor {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
 	if l, ok := n.neighbors[d]; ok {
@@ -126,7 +125,7 @@ func (n *Neighbors) Clear() {
 	libol.Debug("Neighbor.Clear")
 	n.lock.Lock()
 	defer n.lock.Unlock()
-	deletes := make([]uint32, 0, 1024)
+	deletes := make([]string, 0, 1024)
 	for index := range n.neighbors {
 		deletes = append(deletes, index)
 	}
@@ -141,8 +140,7 @@ func (n *Neighbors) Clear() {
 func (n *Neighbors) GetByBytes(d []byte) *Neighbor {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
-	k := binary.BigEndian.Uint32(d)
-	if l, ok := n.neighbors[k]; ok {
+	if l, ok := n.neighbors[string(d)]; ok {
 		return l
 	}
 	return nil
@@ -35,9 +35,15 @@ func (p *Point) AddAddr(ipStr string) error {
 	if ipStr == "" {
 		return nil
 	}
-	// add point-to-point
-	ips := strings.SplitN(ipStr, "/", 2)
-	out, err := libol.IpAddrAdd(p.IfName(), ips[0], ips[0])
+	var out []byte
+	var err error
+	if libol.IsIPv6(ipStr) {
+		out, err = libol.IpAddrAdd(p.IfName(), ipStr)
+	} else {
+		// add point-to-point
+		ips := strings.SplitN(ipStr, "/", 2)
+		out, err = libol.IpAddrAdd(p.IfName(), ips[0], ips[0])
+	}
 	if err != nil {
 		p.out.Warn("Point.AddAddr: %s, %s", err, out)
 		return err
@@ -33,6 +33,7 @@ func (p *Point) Initialize() {
 	p.worker.listener.AddRoutes = p.AddRoutes
 	p.worker.listener.DelRoutes = p.DelRoutes
 	p.worker.listener.OnTap = p.OnTap
+	p.worker.listener.OnJoin = p.OnJoin
 	p.MixPoint.Initialize()
 }
 
@@ -139,6 +140,40 @@ func (p *Point) OnTap(w *TapWorker) error {
 	return nil
 }
 
+// OnJoin adds device of network joined into its bridge, and configures
+// address of it.
+func (p *Point) OnJoin(w *TapWorker, join *config.Join) error {
+	name := w.device.Name()
+	p.out.Info("Point.OnJoin: %s on %s", join.Network, name)
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		p.out.Error("Point.OnJoin: Get %s: %s", name, err)
+		return err
+	}
+	brName := join.Interface.Bridge
+	if br := p.UpBr(brName); br != nil {
+		if err := netlink.LinkSetMaster(link, br); err != nil {
+			p.out.Error("Point.OnJoin.AddSlave: Switch dev %s: %s", name, err)
+		}
+		if link, err = netlink.LinkByName(brName); err != nil {
+			p.out.Error("Point.OnJoin: Get %s: %s", brName, err)
+			return err
+		}
+	}
+	if join.Interface.Address == "" {
+		return nil
+	}
+	ipAddr, err := netlink.ParseAddr(join.Interface.Address)
+	if err != nil {
+		p.out.Error("Point.OnJoin.ParseCIDR %s: %s", join.Interface.Address, err)
+		return err
+	}
+	if err := netlink.AddrAdd(link, ipAddr); err != nil {
+		p.out.Warn("Point.OnJoin.SetLinkIp: %s", err)
+	}
+	return nil
+}
+
 func (p *Point) AddRoutes(routes []*models.Route) error {
 	if routes == nil || p.link == nil {
 		return nil
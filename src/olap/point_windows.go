@@ -57,7 +57,7 @@ func (p *Point) AddAddr(ipStr string) error {
 		return nil
 	}
 	addrExisted := libol.IpAddrShow(p.IfName())
-	if len(addrExisted) > 0 {
+	if len(addrExisted) > 0 && !libol.IsIPv6(ipStr) {
 		for _, addr := range addrExisted {
 			_, _ = libol.IpAddrDel(p.IfName(), addr)
 		}
@@ -24,6 +24,7 @@ type Pointer interface {
 	Alias() string
 	Config() *config.Point
 	Network() *models.Network
+	Probes() []models.SwitchProbe
 }
 
 type MixPoint struct {
@@ -83,8 +84,12 @@ func (p *MixPoint) Status() libol.SocketStatus {
 	return client.Status()
 }
 
+// Addr returns address of switch active, and is a backup after fail over.
 func (p *MixPoint) Addr() string {
-	return p.config.Connection
+	if p.worker.conWorker == nil {
+		return p.config.Connection
+	}
+	return p.worker.conWorker.Switch()
 }
 
 func (p *MixPoint) IfName() string {
@@ -135,6 +140,13 @@ func (p *MixPoint) Record() map[string]int64 {
 	return rt.Data()
 }
 
+func (p *MixPoint) Probes() []models.SwitchProbe {
+	if p.worker.conWorker == nil {
+		return nil
+	}
+	return p.worker.conWorker.Probes()
+}
+
 func (p *MixPoint) Config() *config.Point {
 	return p.config
 }
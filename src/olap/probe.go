@@ -0,0 +1,56 @@
+package olap
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/src/cli/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"time"
+)
+
+const probeWait = 3 // seconds to wait reply of probe.
+
+// probeSwitch returns ms of round trip to switch without login, and -1 if
+// it is unreachable.
+func probeSwitch(c *config.Point, addr string) int64 {
+	client := GetSocketClient(c)
+	client.SetAddress(addr) // pin of certificate is by address.
+	client.SetMaxSize(c.Interface.IfMtu)
+	result := make(chan int64, 1)
+	libol.Go(func() {
+		if err := client.Connect(); err != nil {
+			result <- -1
+			return
+		}
+		if client.Have(libol.ClTerminal) { // already timeout.
+			client.Close()
+			return
+		}
+		sent := time.Now()
+		body, _ := json.Marshal(&PingMsg{DateTime: sent.UnixNano()})
+		if err := client.WriteMsg(libol.NewControlFrame(libol.ProbeReq, body)); err != nil {
+			result <- -1
+			return
+		}
+		for {
+			frame, err := client.ReadMsg()
+			if err != nil {
+				result <- -1
+				return
+			}
+			frame.Decode()
+			action, _ := frame.CmdAndParams()
+			frame.Free()
+			if action == libol.ProbeResp {
+				result <- int64(time.Since(sent) / time.Millisecond)
+				return
+			}
+		}
+	})
+	latency := int64(-1)
+	select {
+	case latency = <-result:
+	case <-time.After(probeWait * time.Second):
+	}
+	client.Terminal()
+	return latency
+}
@@ -1,6 +1,7 @@
 package olap
 
 import (
+	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/songgao/water"
 	"testing"
 )
@@ -80,3 +81,30 @@ func BenchmarkTapWrite1500(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkTapWriteFrame1500(b *testing.B) {
+	cfg := water.Config{DeviceType: water.TAP}
+	dev, err := water.New(cfg)
+	if err != nil {
+		b.Errorf("Tap.open %s", err)
+		return
+	}
+
+	data := make([]byte, 1500)
+	for i := 0; i < len(data); i++ {
+		data[i] = uint8(i)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		frame := libol.NewFrameMessage()
+		frame.Append(data)
+		n, err := dev.Write(frame.Frame()[:frame.Size()])
+		if err != nil {
+			b.Errorf("Tap.write: %s", err)
+		}
+		if n != len(data) {
+			b.Errorf("Tap.write: %d", n)
+		}
+		frame.Free()
+	}
+}
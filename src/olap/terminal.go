@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/chzyer/readline"
 	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/models"
 	"io"
 	"strings"
 )
@@ -26,6 +27,7 @@ func NewTerminal(pointer Pointer) *Terminal {
 			readline.PcItem("config"),
 			readline.PcItem("network"),
 			readline.PcItem("record"),
+			readline.PcItem("probe"),
 			readline.PcItem("statistics"),
 		),
 		readline.PcItem("edit",
@@ -65,6 +67,11 @@ func (t *Terminal) CmdShow(args string) {
 		if out, err := libol.Marshal(v, true); err == nil {
 			fmt.Printf("%s\n", out)
 		}
+	case "probe":
+		v := t.Pointer.Probes()
+		if out, err := libol.Marshal(v, true); err == nil {
+			fmt.Printf("%s\n", out)
+		}
 	case "statistics":
 		if c := t.Pointer.Client(); c != nil {
 			v := c.Statistics()
@@ -78,7 +85,13 @@ func (t *Terminal) CmdShow(args string) {
 			fmt.Printf("%s\n", str)
 		}
 	case "network":
-		cfg := t.Pointer.Network()
+		cfg := struct {
+			*models.Network
+			Switch string `json:"switch"`
+		}{
+			Network: t.Pointer.Network(),
+			Switch:  t.Pointer.Addr(),
+		}
 		if str, err := libol.Marshal(cfg, true); err == nil {
 			fmt.Printf("%s\n", str)
 		}
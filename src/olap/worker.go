@@ -13,6 +13,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,6 +37,8 @@ var (
 	EvSocSuccess = "success"
 	EvSocSignIn  = "signIn"
 	EvSocLogin   = "login"
+	EvSocBack    = "failBack"
+	EvSocSelect  = "select"
 	EvTapIpAddr  = "ipAddr"
 	EvTapReadErr = "readErr"
 	EvTapReset   = "reset"
@@ -68,6 +71,11 @@ type SocketWorkerListener struct {
 	ReadAt    func(frame *libol.FrameMessage) error
 }
 
+const (
+	mtuInterval = 300 // seconds to probe path MTU again.
+	mtuWait     = 3   // seconds to wait replies of probes.
+)
+
 type jobTimer struct {
 	Time int64
 	Call func() error
@@ -84,6 +92,11 @@ const (
 	rtIpAddr    = "addrAt"   // record last receive ipAddr message after success.
 	rtConnects  = "conns"    // record times of reconnecting
 	rtLatency   = "latency"  // latency by ping.
+	rtFailed    = "failAt"   // record time when connecting failed.
+	rtSwitch    = "switch"   // record index of switch active.
+	rtSwitched  = "switchAt" // record time when switch changed.
+	rtProbed    = "probeAt"  // record time when primary probed.
+	rtSelected  = "selectAt" // record time when switches probed to select.
 )
 
 type SocketWorker struct {
@@ -104,6 +117,13 @@ type SocketWorker struct {
 	record     *libol.SafeStrInt64
 	out        *libol.SubLogger
 	wlFrame    *libol.FrameMessage // Last frame from write.
+	mtuLock    sync.Mutex
+	mtuTime    int64 // unix time of last probe.
+	mtuWait    bool
+	mtuBest    int          // largest probe replied.
+	switches   []string     // primary is first, and others are backups.
+	probes     atomic.Value // []models.SwitchProbe last.
+	caps       []string     // negotiated by login.
 }
 
 func NewSocketWorker(client libol.SocketClient, c *config.Point) *SocketWorker {
@@ -119,13 +139,19 @@ func NewSocketWorker(client libol.SocketClient, c *config.Point) *SocketWorker {
 		writeQueue: make(chan *libol.FrameMessage, c.Queue.SockWr),
 		jobber:     make([]jobTimer, 0, 32),
 		out:        libol.NewSubLogger(c.Id()),
+		switches:   c.Switches(),
 	}
 	t.user = &models.User{
-		Alias:    c.Alias,
-		Name:     c.Username,
-		Password: c.Password,
-		Network:  c.Network,
-		System:   runtime.GOOS,
+		Alias:      c.Alias,
+		Name:       c.Username,
+		Password:   c.Password,
+		Network:    c.Network,
+		System:     runtime.GOOS,
+		Protocol:   libol.Protocol,
+		Capability: c.Capabilities(),
+	}
+	if c.Compress != "" {
+		t.user.Compress = []string{c.Compress}
 	}
 	t.keepalive = KeepAlive{
 		Interval: 15,
@@ -172,6 +198,10 @@ func (t *SocketWorker) Start() {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 	t.out.Info("SocketWorker.Start")
+	if t.pinCfg.Select != nil && len(t.switches) > 1 {
+		t.record.Set(rtSelected, time.Now().Unix())
+		t.startSelect(t.probeAll(-1))
+	}
 	_ = t.connect()
 	libol.Go(t.Loop)
 }
@@ -239,12 +269,232 @@ func (t *SocketWorker) connect() error {
 	}
 	t.record.Add(rtConnects, 1)
 	if err := t.client.Connect(); err != nil {
+		t.record.Set(rtFailed, time.Now().Unix())
 		t.out.Error("SocketWorker.connect: %s %s", t.client, err)
 		return err
 	}
 	return nil
 }
 
+// Alive returns true if logged in and pong received in time.
+func (t *SocketWorker) Alive() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.client == nil || !t.client.Have(libol.ClAuth) {
+		return false
+	}
+	return time.Now().Unix()-t.record.Get(rtLive) < 3*t.keepalive.Interval
+}
+
+// Bonded returns true if switch shares one device among links of bond,
+// otherwise it floods broadcast to every link.
+func (t *SocketWorker) Bonded() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return libol.HasCapability(t.caps, libol.CapBond)
+}
+
+// Latency returns ms of last ping.
+func (t *SocketWorker) Latency() int64 {
+	return t.record.Get(rtLatency)
+}
+
+// Switch returns address of switch active.
+func (t *SocketWorker) Switch() string {
+	return t.switches[t.record.Get(rtSwitch)]
+}
+
+func (t *SocketWorker) setSwitch(index int) {
+	addr := t.switches[index]
+	t.out.Info("SocketWorker.setSwitch: %s to %s", t.Switch(), addr)
+	t.client.SetAddress(addr)
+	t.record.Set(rtSwitch, int64(index))
+	t.record.Set(rtSwitched, time.Now().Unix())
+}
+
+// failover moves to next switch if connecting failed or login not
+// success since last connected, and a lost keepalive retries same one.
+func (t *SocketWorker) failover() {
+	if len(t.switches) < 2 {
+		return
+	}
+	rtConn := t.record.Get(rtConnected)
+	if t.record.Get(rtFailed) < rtConn && t.record.Get(rtSuccess) >= rtConn {
+		return
+	}
+	next := (int(t.record.Get(rtSwitch)) + 1) % len(t.switches)
+	if next != 0 { // try backup at once, and delay again after all failed.
+		t.record.Set(rtSleeps, 0)
+	}
+	t.setSwitch(next)
+}
+
+// checkFailBack probes primary switch after staying on backup long enough,
+// and never fails back by udp or kcp because no way to probe it.
+func (t *SocketWorker) checkFailBack() {
+	back := int64(t.pinCfg.FailBack)
+	if back == 0 || t.pinCfg.Select != nil || t.record.Get(rtSwitch) == 0 || !t.client.Have(libol.ClAuth) {
+		return
+	}
+	if t.pinCfg.Protocol == "udp" || t.pinCfg.Protocol == "kcp" {
+		return
+	}
+	now := time.Now().Unix()
+	if now-t.record.Get(rtSwitched) < back || now-t.record.Get(rtProbed) < back {
+		return
+	}
+	t.record.Set(rtProbed, now)
+	addr := t.switches[0]
+	proxy := config.GetProxy(t.pinCfg.Proxy)
+	libol.Go(func() {
+		var conn net.Conn
+		var err error
+		if proxy != nil && !proxy.Bypass(addr) {
+			proxy.Timeout = 5 * time.Second
+			conn, err = proxy.Dial(addr)
+		} else {
+			conn, err = net.DialTimeout("tcp", addr, 5*time.Second)
+		}
+		if err != nil {
+			t.out.Debug("SocketWorker.checkFailBack: %s", err)
+			return
+		}
+		_ = conn.Close()
+		t.eventQueue <- NewEvent(EvSocBack, "from fail back")
+	})
+}
+
+func (t *SocketWorker) failBack() {
+	if t.isStopped() || t.record.Get(rtSwitch) == 0 {
+		return
+	}
+	t.migrate(0)
+}
+
+// migrate closes connection, and reconnects to switch by reading closed.
+func (t *SocketWorker) migrate(index int) {
+	t.leave()
+	t.setSwitch(index)
+	t.client.Close()
+}
+
+// probeAll probes latency of all switches at same time, and skips the
+// active one which is measured by ping.
+func (t *SocketWorker) probeAll(active int) []models.SwitchProbe {
+	probes := make([]models.SwitchProbe, len(t.switches))
+	wg := sync.WaitGroup{}
+	for i, addr := range t.switches {
+		i, addr := i, addr
+		if i == active {
+			probes[i] = models.SwitchProbe{Address: addr, Latency: -1}
+			continue
+		}
+		wg.Add(1)
+		libol.Go(func() {
+			probes[i] = models.SwitchProbe{
+				Address: addr,
+				Latency: probeSwitch(t.pinCfg, addr),
+				ProbeAt: time.Now().Unix(),
+			}
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	return probes
+}
+
+// Probes returns latency of switches probed last.
+func (t *SocketWorker) Probes() []models.SwitchProbe {
+	if v, ok := t.probes.Load().([]models.SwitchProbe); ok {
+		return v
+	}
+	return nil
+}
+
+// startSelect uses switch of lowest latency before connecting.
+func (t *SocketWorker) startSelect(probes []models.SwitchProbe) {
+	best := -1
+	for i, p := range probes {
+		if p.Latency >= 0 && (best == -1 || p.Latency < probes[best].Latency) {
+			best = i
+		}
+	}
+	if best > 0 {
+		t.setSwitch(best)
+	}
+	for i := range probes {
+		probes[i].Active = i == best
+	}
+	t.probes.Store(probes)
+}
+
+// checkSelect probes other switches in interval, and latency of active
+// one is by ping of the connection.
+func (t *SocketWorker) checkSelect() {
+	sel := t.pinCfg.Select
+	if sel == nil || len(t.switches) < 2 {
+		return
+	}
+	now := time.Now().Unix()
+	if now-t.record.Get(rtSelected) < int64(sel.Interval) {
+		return
+	}
+	t.record.Set(rtSelected, now)
+	cur := int(t.record.Get(rtSwitch))
+	latency := int64(-1)
+	if t.client.Have(libol.ClAuth) && t.record.Get(rtLive) >= t.record.Get(rtConnected) {
+		latency = t.record.Get(rtLatency)
+	}
+	libol.Go(func() {
+		probes := t.probeAll(cur)
+		probes[cur].Latency = latency
+		probes[cur].ProbeAt = now
+		ev := NewEvent(EvSocSelect, "from probe")
+		ev.Data = probes
+		t.eventQueue <- ev
+	})
+}
+
+// onSelect counts times of switch better than active by margin in a row,
+// and migrates to the best one if enough.
+func (t *SocketWorker) onSelect(probes []models.SwitchProbe) {
+	if t.isStopped() {
+		return
+	}
+	sel := t.pinCfg.Select
+	older := t.Probes()
+	cur := int(t.record.Get(rtSwitch))
+	active := probes[cur].Latency
+	best := -1
+	for i := range probes {
+		p := &probes[i]
+		p.Active = i == cur
+		if p.Active || p.Latency < 0 {
+			continue
+		}
+		if active >= 0 && p.Latency+int64(sel.Margin) > active {
+			continue
+		}
+		if len(older) == len(probes) {
+			p.Better = older[i].Better
+		}
+		p.Better++
+		if best == -1 || p.Latency < probes[best].Latency {
+			best = i
+		}
+	}
+	if best == -1 || probes[best].Better < sel.Times {
+		t.probes.Store(probes)
+		return
+	}
+	probes[cur].Active = false
+	probes[best].Active = true
+	probes[best].Better = 0
+	t.probes.Store(probes)
+	t.out.Info("SocketWorker.onSelect: %s in %dms", t.switches[best], probes[best].Latency)
+	t.migrate(best)
+}
+
 func (t *SocketWorker) reconnect() {
 	if t.isStopped() {
 		return
@@ -264,6 +514,7 @@ func (t *SocketWorker) reconnect() {
 			}
 			t.out.Info("SocketWorker.reconnect: l: %d a: %d", rtLast, rtLive)
 			t.out.Info("SocketWorker.reconnect: c: %d r: %d", rtConn, rtReCon)
+			t.failover()
 			return t.connect()
 		},
 	}
@@ -340,8 +591,17 @@ func (t *SocketWorker) onLogin(resp []byte) error {
 		t.out.Cmd("SocketWorker.onLogin: %s", resp)
 		return nil
 	}
-	if strings.HasPrefix(string(resp), "okay") {
+	login := models.ParseLogin(resp)
+	if login.IsOkay() {
 		t.client.SetStatus(libol.ClAuth)
+		t.caps = libol.Negotiate(t.user.Capability, login.Capability)
+		t.client.SetCapability(t.caps)
+		t.sendJoin(t.client)
+		if login.Compress != "" {
+			if err := t.client.SetCompress(login.Compress); err != nil {
+				t.out.Warn("SocketWorker.onLogin: %s", err)
+			}
+		}
 		if t.listener.OnSuccess != nil {
 			_ = t.listener.OnSuccess(t)
 		}
@@ -349,10 +609,47 @@ func (t *SocketWorker) onLogin(resp []byte) error {
 		t.record.Set(rtIpAddr, 0)
 		t.record.Set(rtSuccess, time.Now().Unix())
 		t.eventQueue <- NewEvent(EvSocSuccess, "from login")
-		t.out.Info("SocketWorker.onLogin: success")
+		t.out.Info("SocketWorker.onLogin: success on protocol %d", login.Protocol)
 	} else {
 		t.client.SetStatus(libol.ClUnAuth)
-		t.out.Error("SocketWorker.onLogin: %s", resp)
+		t.out.Error("SocketWorker.onLogin: %s", login)
+	}
+	return nil
+}
+
+// sendJoin joins networks configured, and channel is index of them from 1.
+func (t *SocketWorker) sendJoin(client libol.SocketClient) {
+	name := strings.SplitN(t.user.Name, "@", 2)[0]
+	for i, join := range t.pinCfg.Join {
+		user := &models.User{
+			Alias:    t.user.Alias,
+			Name:     name,
+			Password: join.Password,
+			Network:  join.Network,
+			UUID:     t.user.UUID,
+			System:   t.user.System,
+			Protocol: libol.Protocol,
+			Channel:  uint8(i + 1),
+		}
+		body, err := json.Marshal(user)
+		if err != nil {
+			t.out.Error("SocketWorker.sendJoin: %s", err)
+			continue
+		}
+		t.out.Cmd("SocketWorker.sendJoin: %s", body)
+		m := libol.NewControlFrame(libol.JoinReq, body)
+		if err := client.WriteMsg(m); err != nil {
+			t.out.Error("SocketWorker.sendJoin: %s", err)
+		}
+	}
+}
+
+func (t *SocketWorker) onJoin(resp []byte) error {
+	login := models.ParseLogin(resp)
+	if login.IsOkay() {
+		t.out.Info("SocketWorker.onJoin: channel %d success", login.Channel)
+	} else {
+		t.out.Error("SocketWorker.onJoin: channel %d %s", login.Channel, login)
 	}
 	return nil
 }
@@ -409,12 +706,18 @@ func (t *SocketWorker) onInstruct(frame *libol.FrameMessage) error {
 	switch action {
 	case libol.LoginResp:
 		return t.onLogin(resp)
+	case libol.JoinResp:
+		return t.onJoin(resp)
 	case libol.IpAddrResp:
 		t.record.Set(rtIpAddr, time.Now().Unix())
 		return t.onIpAddr(resp)
 	case libol.PongResp:
 		t.record.Set(rtLive, time.Now().Unix())
 		return t.onPong(resp)
+	case libol.PingReq:
+		return t.onPing(resp)
+	case libol.MtuResp:
+		return t.onMtu(resp)
 	case libol.SignReq:
 		return t.onSignIn(resp)
 	case libol.LeftReq:
@@ -425,6 +728,12 @@ func (t *SocketWorker) onInstruct(frame *libol.FrameMessage) error {
 	return nil
 }
 
+// onPing replies keepalive of virtual switch.
+func (t *SocketWorker) onPing(data []byte) error {
+	m := libol.NewControlFrame(libol.PongResp, data)
+	return t.client.WriteMsg(m)
+}
+
 type PingMsg struct {
 	DateTime   int64  `json:"datetime"`
 	UUID       string `json:"uuid"`
@@ -433,6 +742,87 @@ type PingMsg struct {
 	Address    string `json:"address"`
 }
 
+// probeMtu sends probes of sizes not larger than interface, and only UDP
+// is probed because stream transports segment every probe.
+func (t *SocketWorker) probeMtu(client libol.SocketClient) {
+	if client == nil || t.pinCfg.Protocol != "udp" {
+		return
+	}
+	max := t.pinCfg.Interface.IfMtu + libol.EtherLen
+	overhead := client.Overhead()
+	t.mtuLock.Lock()
+	t.mtuTime = time.Now().Unix()
+	t.mtuWait = true
+	t.mtuBest = 0
+	t.mtuLock.Unlock()
+	for _, path := range libol.MtuPaths {
+		size := path - overhead
+		if size > max {
+			continue
+		}
+		if err := client.WriteMsg(libol.NewMtuProbe(size)); err != nil {
+			t.out.Debug("SocketWorker.probeMtu: %d %s", size, err)
+		}
+	}
+}
+
+func (t *SocketWorker) onMtu(data []byte) error {
+	probe, err := libol.DecodeMtuProbe(data)
+	if err != nil {
+		t.out.Error("SocketWorker.onMtu: %s", err)
+		return err
+	}
+	t.mtuLock.Lock()
+	defer t.mtuLock.Unlock()
+	if t.mtuWait && probe.Size > t.mtuBest {
+		t.mtuBest = probe.Size
+	}
+	return nil
+}
+
+// reportMtu saves the largest probe replied as path MTU, and tells switch
+// to clamp MSS of TCP by it.
+func (t *SocketWorker) reportMtu(client libol.SocketClient) {
+	t.mtuLock.Lock()
+	best := t.mtuBest
+	t.mtuWait = false
+	t.mtuLock.Unlock()
+	if best == 0 {
+		t.out.Warn("SocketWorker.reportMtu: no probe replied")
+		return
+	}
+	mtu := best - libol.EtherLen
+	client.SetMtu(mtu)
+	t.out.Info("SocketWorker.reportMtu: %d", mtu)
+	body, err := json.Marshal(&libol.MtuProbe{Mtu: mtu})
+	if err != nil {
+		return
+	}
+	if err := client.WriteMsg(libol.NewControlFrame(libol.MtuReq, body)); err != nil {
+		t.out.Error("SocketWorker.reportMtu: %s", err)
+	}
+}
+
+// checkMtu probes path MTU periodically.
+func (t *SocketWorker) checkMtu() {
+	if t.client == nil || !t.client.Have(libol.ClAuth) {
+		return
+	}
+	now := time.Now().Unix()
+	t.mtuLock.Lock()
+	wait, last := t.mtuWait, t.mtuTime
+	t.mtuLock.Unlock()
+	if wait {
+		if now-last >= mtuWait {
+			t.reportMtu(t.client)
+		}
+		return
+	}
+	if now-last >= mtuInterval {
+		t.probeMtu(t.client)
+	}
+}
+
 func (t *SocketWorker) sendPing(client libol.SocketClient) error {
 	if client == nil {
 		return libol.NewErr("client is nil")
@@ -510,6 +900,9 @@ func (t *SocketWorker) doTicker() error {
 	t.checkAlive()  // period to check whether alive.
 	t.keepAlive()   // send ping and wait pong to keep alive.
 	t.checkJobber() // period to check job whether timeout.
+	t.checkMtu()    // period to probe path MTU.
+	t.checkFailBack()
+	t.checkSelect()
 	return nil
 }
 
@@ -526,9 +919,16 @@ func (t *SocketWorker) dispatch(ev *WorkerEvent) {
 	case EvSocSuccess:
 		_ = t.toNetwork(t.client)
 		_ = t.sendPing(t.client)
+		t.probeMtu(t.client)
 	case EvSocRecon:
 		t.out.Info("SocketWorker.dispatch: %v", ev)
 		t.reconnect()
+	case EvSocBack:
+		t.failBack()
+	case EvSocSelect:
+		if probes, ok := ev.Data.([]models.SwitchProbe); ok {
+			t.onSelect(probes)
+		}
 	case EvSocSignIn, EvSocLogin:
 		_ = t.toLogin(t.client)
 	}
@@ -543,6 +943,7 @@ func (t *SocketWorker) Loop() {
 			t.lock.Unlock()
 		case d := <-t.writeQueue:
 			_ = t.DoWrite(d)
+			d.Free()
 		case <-t.done:
 			return
 		case c := <-t.ticker.C:
@@ -570,6 +971,7 @@ func (t *SocketWorker) Read(client libol.SocketClient) {
 			t.out.Debug("SocketWorker.Read: %x", data)
 		}
 		if data.Size() <= 0 {
+			data.Free()
 			continue
 		}
 		data.Decode()
@@ -577,6 +979,7 @@ func (t *SocketWorker) Read(client libol.SocketClient) {
 			t.lock.Lock()
 			_ = t.onInstruct(data)
 			t.lock.Unlock()
+			data.Free()
 			continue
 		}
 		t.record.Set(rtLast, time.Now().Unix())
@@ -639,6 +1042,14 @@ func (t *SocketWorker) SetUUID(v string) {
 	t.user.UUID = v
 }
 
+// SetBond logs in as link of bond by uuid of point.
+func (t *SocketWorker) SetBond(v string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.user.Bond = v
+	t.user.Capability = append(t.user.Capability, libol.CapBond)
+}
+
 type TapWorkerListener struct {
 	OnOpen   func(w *TapWorker) error
 	OnClose  func(w *TapWorker)
@@ -647,8 +1058,9 @@ type TapWorkerListener struct {
 }
 
 type TunEther struct {
-	HwAddr []byte
-	IpAddr []byte
+	HwAddr  []byte
+	IpAddr  []byte
+	IpAddr6 []byte
 }
 
 type TapWorker struct {
@@ -685,7 +1097,7 @@ func (a *TapWorker) Initialize() {
 
 	a.out.Info("TapWorker.Initialize")
 	a.neighbor = Neighbors{
-		neighbors: make(map[uint32]*Neighbor, 1024),
+		neighbors: make(map[string]*Neighbor, 1024),
 		done:      make(chan bool),
 		ticker:    time.NewTicker(5 * time.Second),
 		timeout:   3 * 60,
@@ -714,6 +1126,10 @@ func (a *TapWorker) IsTun() bool {
 }
 
 func (a *TapWorker) setEther(ipAddr string, hwAddr []byte) {
+	if libol.IsIPv6(ipAddr) {
+		a.setEther6(ipAddr)
+		return
+	}
 	a.neighbor.Clear()
 	// format ip address.
 	ipAddr, err := libol.IPNetmask(ipAddr)
@@ -738,6 +1154,16 @@ func (a *TapWorker) setEther(ipAddr string, hwAddr []byte) {
 	a.ifAddr = ipAddr
 }
 
+func (a *TapWorker) setEther6(ipAddr string) {
+	ip, _, err := net.ParseCIDR(ipAddr)
+	if err != nil {
+		a.out.Warn("TapWorker.setEther6: %s: %s", ipAddr, err)
+		return
+	}
+	a.ether.IpAddr6 = ip.To16()
+	a.out.Info("TapWorker.setEther6: srcIp %s", ip)
+}
+
 func (a *TapWorker) OnIpAddr(addr string) {
 	a.eventQueue <- NewEvent(EvTapIpAddr, addr)
 }
@@ -776,6 +1202,10 @@ func (a *TapWorker) OnArpAlive(dest []byte) {
 
 // process if ethernet destination is missed
 func (a *TapWorker) onMiss(dest []byte) {
+	if len(dest) == net.IPv6len {
+		a.onMiss6(dest)
+		return
+	}
 	a.out.Debug("TapWorker.onMiss: %v.", dest)
 	eth := a.newEth(libol.EthArp, libol.EthAll)
 	reply := libol.NewArp()
@@ -794,25 +1224,79 @@ func (a *TapWorker) onMiss(dest []byte) {
 	}
 }
 
+// send neighbor solicitation if ethernet destination of IPv6 is missed
+func (a *TapWorker) onMiss6(dest []byte) {
+	if a.ether.IpAddr6 == nil {
+		return
+	}
+	a.out.Debug("TapWorker.onMiss6: %v.", net.IP(dest))
+	req := libol.NewNdp()
+	copy(req.Target, dest)
+	req.Option = libol.NdpSrcLinkAddr
+	req.HwAddr = a.ether.HwAddr
+	ipDst := libol.Ip6SolicitedNode(dest)
+	frame := a.newNdp(libol.Icmp6NeighSolicit, libol.Ip6McastEth(ipDst), ipDst, req)
+	if a.listener.ReadAt != nil {
+		_ = a.listener.ReadAt(frame)
+	}
+}
+
+func (a *TapWorker) newNdp(t uint8, ethDst, ipDst []byte, ndp *libol.Ndp) *libol.FrameMessage {
+	icmp := libol.NewIcmpv6(t)
+	data := ndp.Encode()
+	icmp.Checksum = libol.Icmpv6Checksum(a.ether.IpAddr6, ipDst, append(icmp.Encode(), data...))
+	data = append(icmp.Encode(), data...)
+	iph := libol.NewIpv6()
+	iph.NextHeader = libol.IpIcmp6
+	iph.PayloadLen = uint16(len(data))
+	copy(iph.Source, a.ether.IpAddr6)
+	copy(iph.Destination, ipDst)
+
+	eth := a.newEth(libol.EthIp6, ethDst)
+	frame := libol.NewFrameMessage()
+	frame.Append(eth.Encode())
+	frame.Append(iph.Encode())
+	frame.Append(data)
+	return frame
+}
+
 func (a *TapWorker) onFrame(frame *libol.FrameMessage, data []byte) int {
 	size := len(data)
 	if a.IsTun() {
-		iph, err := libol.NewIpv4FromFrame(data)
-		if err != nil {
-			a.out.Warn("TapWorker.onFrame: %s", err)
-			return 0
-		}
-		dest := iph.Destination
-		if a.listener.FindNext != nil {
-			dest = a.listener.FindNext(dest)
+		var dest []byte
+		ethType := uint16(libol.EthIp4)
+		if len(data) > 0 && data[0]>>4 == libol.Ipv6Ver {
+			iph, err := libol.NewIpv6FromFrame(data)
+			if err != nil {
+				a.out.Warn("TapWorker.onFrame: %s", err)
+				return 0
+			}
+			dest = iph.Destination
+			ethType = libol.EthIp6
+		} else {
+			iph, err := libol.NewIpv4FromFrame(data)
+			if err != nil {
+				a.out.Warn("TapWorker.onFrame: %s", err)
+				return 0
+			}
+			dest = iph.Destination
 		}
-		neb := a.neighbor.GetByBytes(dest)
-		if neb == nil {
-			a.onMiss(dest)
-			a.out.Debug("TapWorker.onFrame: onMiss neighbor %v", dest)
-			return 0
+		var hwAddr []byte
+		if ethType == libol.EthIp6 && dest[0] == 0xff { // multicast
+			hwAddr = libol.Ip6McastEth(dest)
+		} else {
+			if a.listener.FindNext != nil {
+				dest = a.listener.FindNext(dest)
+			}
+			neb := a.neighbor.GetByBytes(dest)
+			if neb == nil {
+				a.onMiss(dest)
+				a.out.Debug("TapWorker.onFrame: onMiss neighbor %v", dest)
+				return 0
+			}
+			hwAddr = neb.HwAddr
 		}
-		eth := a.newEth(libol.EthIp4, neb.HwAddr)
+		eth := a.newEth(ethType, hwAddr)
 		frame.Append(eth.Encode()) // insert ethernet header.
 		size += eth.Len
 	}
@@ -835,6 +1319,7 @@ func (a *TapWorker) Read(device network.Taper) {
 				a.out.Debug("TapWorker.Read: %x", data[:n])
 			}
 			if size := a.onFrame(frame, data[:n]); size == 0 {
+				frame.Free()
 				continue
 			}
 			if a.listener.ReadAt != nil {
@@ -867,6 +1352,7 @@ func (a *TapWorker) Loop() {
 			return
 		case d := <-a.writeQueue:
 			_ = a.DoWrite(d)
+			d.Free()
 		case ev := <-a.eventQueue:
 			a.lock.Lock()
 			a.dispatch(ev)
@@ -886,8 +1372,8 @@ func (a *TapWorker) DoWrite(frame *libol.FrameMessage) error {
 		return libol.NewErr("device is nil")
 	}
 	if a.device.IsTun() {
-		// proxy arp request.
-		if a.toArp(data) {
+		// proxy arp request and neighbor solicitation.
+		if a.toArp(data) || a.toNdp(data) {
 			a.lock.Unlock()
 			return nil
 		}
@@ -897,10 +1383,10 @@ func (a *TapWorker) DoWrite(frame *libol.FrameMessage) error {
 			a.lock.Unlock()
 			return nil
 		}
-		if eth.IsIP4() {
+		if eth.IsIP4() || eth.IsIP6() {
 			data = data[14:]
 		} else {
-			a.out.Debug("TapWorker.DoWrite: 0x%04x not IPv4", eth.Type)
+			a.out.Debug("TapWorker.DoWrite: 0x%04x not IP", eth.Type)
 			a.lock.Unlock()
 			return nil
 		}
@@ -975,6 +1461,59 @@ func (a *TapWorker) toArp(data []byte) bool {
 	return true
 }
 
+// learn source from ndp, and reply advertisement for solicitation
+func (a *TapWorker) toNdp(data []byte) bool {
+	proto := &libol.FrameProto{Frame: data}
+	if err := proto.Decode(); err != nil || proto.Ndp == nil {
+		return false
+	}
+	eth, iph, ndp := proto.Eth, proto.Ip6, proto.Ndp
+	switch proto.Icmp6.Type {
+	case libol.Icmp6NeighSolicit:
+		source := net.IP(iph.Source)
+		if ndp.Option == libol.NdpSrcLinkAddr && !source.IsUnspecified() {
+			a.neighbor.Add(&Neighbor{
+				HwAddr:  ndp.HwAddr,
+				IpAddr:  iph.Source,
+				NewTime: time.Now().Unix(),
+				Uptime:  time.Now().Unix(),
+			})
+		}
+		if a.ether.IpAddr6 == nil || !bytes.Equal(ndp.Target, a.ether.IpAddr6) {
+			break
+		}
+		rep := libol.NewNdp()
+		rep.Flags = libol.NdpSolicited | libol.NdpOverride
+		copy(rep.Target, a.ether.IpAddr6)
+		rep.Option = libol.NdpDstLinkAddr
+		rep.HwAddr = a.ether.HwAddr
+		ipDst, ethDst := iph.Source, eth.Src
+		if source.IsUnspecified() { // duplicate address detection.
+			rep.Flags = libol.NdpOverride
+			ipDst = net.IPv6linklocalallnodes
+			ethDst = libol.Ip6McastEth(ipDst)
+		}
+		frame := a.newNdp(libol.Icmp6NeighAdvert, ethDst, ipDst, rep)
+		a.out.Event("TapWorker.toNdp: reply %v on %x.", source, rep.HwAddr)
+		if a.listener.ReadAt != nil {
+			_ = a.listener.ReadAt(frame)
+		}
+	case libol.Icmp6NeighAdvert:
+		hwAddr := eth.Src
+		if ndp.Option == libol.NdpDstLinkAddr {
+			hwAddr = ndp.HwAddr
+		}
+		a.neighbor.Add(&Neighbor{
+			HwAddr:  hwAddr,
+			IpAddr:  ndp.Target,
+			NewTime: time.Now().Unix(),
+			Uptime:  time.Now().Unix(),
+		})
+		a.out.Event("TapWorker.toNdp: recv %v on %x.", net.IP(ndp.Target), hwAddr)
+	}
+	return true
+}
+
 func (a *TapWorker) close() {
 	a.out.Info("TapWorker.close")
 	if a.device != nil {
@@ -1011,6 +1550,7 @@ type WorkerListener struct {
 	AddAddr   func(ipStr string) error
 	DelAddr   func(ipStr string) error
 	OnTap     func(w *TapWorker) error
+	OnJoin    func(w *TapWorker, join *config.Join) error
 	AddRoutes func(routes []*models.Route) error
 	DelRoutes func(routes []*models.Route) error
 }
@@ -1022,56 +1562,88 @@ type PrefixRule struct {
 }
 
 func GetSocketClient(p *config.Point) libol.SocketClient {
+	batch, delay := p.Batch.Get()
+	proxy := config.GetProxy(p.Proxy)
+	if proxy != nil && (p.Protocol == "kcp" || p.Protocol == "udp") {
+		libol.Warn("GetSocketClient: proxy notSupport by %s", p.Protocol)
+	}
 	switch p.Protocol {
 	case "kcp":
 		c := &libol.KcpConfig{
 			Block: config.GetBlock(p.Crypt),
+			Aead:  config.GetAead(p.Crypt, p.Network),
 		}
 		return libol.NewKcpClient(p.Connection, c)
 	case "tcp":
 		c := &libol.TcpConfig{
 			Block: config.GetBlock(p.Crypt),
+			Aead:  config.GetAead(p.Crypt, p.Network),
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
+			Batch: batch,
+			Delay: delay,
+			Proxy: proxy,
 		}
 		return libol.NewTcpClient(p.Connection, c)
 	case "udp":
 		c := &libol.UdpConfig{
 			Block:   config.GetBlock(p.Crypt),
+			Aead:    config.GetAead(p.Crypt, p.Network),
 			Timeout: time.Duration(p.Timeout) * time.Second,
 		}
 		return libol.NewUdpClient(p.Connection, c)
 	case "ws":
 		c := &libol.WebConfig{
 			Block: config.GetBlock(p.Crypt),
+			Aead:  config.GetAead(p.Crypt, p.Network),
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
+			Batch: batch,
+			Delay: delay,
+			Proxy: proxy,
+			Path:  p.Path,
 		}
 		return libol.NewWebClient(p.Connection, c)
 	case "wss":
 		c := &libol.WebConfig{
 			Block: config.GetBlock(p.Crypt),
+			Aead:  config.GetAead(p.Crypt, p.Network),
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
+			Batch: batch,
+			Delay: delay,
+			Proxy: proxy,
+			Path:  p.Path,
 		}
 		if p.Cert != nil {
 			c.Cert = &libol.WebCert{
 				Insecure: p.Cert.Insecure,
 				RootCa:   p.Cert.CaFile,
 			}
+			if p.Cert.HasKeyPair() {
+				c.Cert.Crt = p.Cert.CrtFile
+				c.Cert.Key = p.Cert.KeyFile
+			}
+			c.Cert.Pin = p.GetPin()
 		}
 		return libol.NewWebClient(p.Connection, c)
 	default:
 		c := &libol.TcpConfig{
 			Block: config.GetBlock(p.Crypt),
+			Aead:  config.GetAead(p.Crypt, p.Network),
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
+			Batch: batch,
+			Delay: delay,
+			Proxy: proxy,
 		}
 		if p.Cert != nil {
 			c.Tls = &tls.Config{
 				InsecureSkipVerify: p.Cert.Insecure,
 				RootCAs:            p.Cert.GetCertPool(),
+				Certificates:       p.Cert.GetCertificates(),
 			}
+			c.Pin = p.GetPin()
 		}
 		return libol.NewTcpClient(p.Connection, c)
 	}
@@ -1099,6 +1671,9 @@ type Worker struct {
 	listener  WorkerListener
 	conWorker *SocketWorker
 	tapWorker *TapWorker
+	joins     []*TapWorker    // networks joined, and index is channel - 1.
+	links     []*SocketWorker // links bonded beside conWorker.
+	bonder    *Bonder
 	cfg       *config.Point
 	uuid      string
 	network   *models.Network
@@ -1132,9 +1707,14 @@ func (w *Worker) Initialize() {
 		OnClose:   w.OnClose,
 		OnSuccess: w.OnSuccess,
 		OnIpAddr:  w.OnIpAddr,
-		ReadAt:    w.tapWorker.Write,
+		ReadAt:    w.onRead,
 	}
 	w.conWorker.Initialize()
+	writeAt := w.conWorker.Write
+	if w.cfg.Bond != nil && len(w.cfg.Bond.Links) > 0 {
+		w.newBond()
+		writeAt = w.bonder.Write
+	}
 
 	w.tapWorker.listener = TapWorkerListener{
 		OnOpen: func(t *TapWorker) error {
@@ -1151,16 +1731,95 @@ func (w *Worker) Initialize() {
 			}
 			return nil
 		},
-		ReadAt:   w.conWorker.Write,
+		ReadAt:   writeAt,
 		FindNext: w.FindNext,
 	}
 	w.tapWorker.Initialize()
+	for i, join := range w.cfg.Join {
+		w.joins = append(w.joins, w.newJoin(uint8(i+1), join))
+	}
+}
+
+// newBond connects links of bond, and which only carry frames of device.
+func (w *Worker) newBond() {
+	w.conWorker.SetBond(w.UUID())
+	links := []*SocketWorker{w.conWorker}
+	for i, addr := range w.cfg.Bond.Links {
+		cfg := *w.cfg
+		cfg.Connection = addr
+		cfg.Backup = nil
+		cfg.Bond = nil
+		cfg.Select = nil
+		cfg.Join = nil
+		cfg.RequestAddr = false
+		cfg.Interface.Address = ""
+		link := NewSocketWorker(GetSocketClient(&cfg), &cfg)
+		link.SetUUID(fmt.Sprintf("%s.%d", w.UUID(), i+1))
+		link.SetBond(w.UUID())
+		links = append(links, link)
+	}
+	bonded := make([]bondLink, 0, len(links))
+	for _, link := range links {
+		bonded = append(bonded, link)
+	}
+	w.bonder = NewBonder(w.cfg.Bond, bonded)
+	w.conWorker.listener.ReadAt = w.bonder.ReadAt(w.conWorker, w.onRead)
+	for _, link := range links[1:] {
+		link.listener = SocketWorkerListener{
+			ReadAt: w.bonder.ReadAt(link, w.tapWorker.Write),
+		}
+		link.Initialize()
+	}
+	w.links = links[1:]
+}
+
+// newJoin returns worker of device for network joined by channel.
+func (w *Worker) newJoin(channel uint8, join *config.Join) *TapWorker {
+	cfg := *w.cfg
+	cfg.Network = join.Network
+	cfg.Interface = join.Interface
+	tap := NewTapWorker(GetTapCfg(&cfg), &cfg)
+	tap.listener = TapWorkerListener{
+		OnOpen: func(t *TapWorker) error {
+			if w.listener.OnJoin != nil {
+				return w.listener.OnJoin(t, join)
+			}
+			return nil
+		},
+		ReadAt: func(frame *libol.FrameMessage) error {
+			frame.SetChannel(channel)
+			return w.conWorker.Write(frame)
+		},
+	}
+	tap.Initialize()
+	return tap
+}
+
+// onRead writes frame to device of its channel.
+func (w *Worker) onRead(frame *libol.FrameMessage) error {
+	if ch := int(frame.Channel()); ch > 0 {
+		if ch > len(w.joins) {
+			frame.Free()
+			return nil
+		}
+		return w.joins[ch-1].Write(frame)
+	}
+	return w.tapWorker.Write(frame)
 }
 
 func (w *Worker) Start() {
 	w.out.Debug("Worker.Start linux.")
 	w.tapWorker.Start()
+	for _, tap := range w.joins {
+		tap.Start()
+	}
 	w.conWorker.Start()
+	for _, link := range w.links {
+		link.Start()
+	}
+	if w.bonder != nil {
+		w.bonder.Start()
+	}
 }
 
 func (w *Worker) Stop() {
@@ -1168,10 +1827,21 @@ func (w *Worker) Stop() {
 		return
 	}
 	w.FreeIpAddr()
+	if w.bonder != nil {
+		w.bonder.Stop()
+	}
+	for _, link := range w.links {
+		link.Stop()
+	}
 	w.conWorker.Stop()
 	w.tapWorker.Stop()
+	for _, tap := range w.joins {
+		tap.Stop()
+	}
 	w.conWorker = nil
 	w.tapWorker = nil
+	w.joins = nil
+	w.links = nil
 }
 
 func (w *Worker) UpTime() int64 {
@@ -1193,7 +1863,10 @@ func (w *Worker) FindNext(dest []byte) []byte {
 		if w.out.Has(libol.DEBUG) {
 			w.out.Debug("Worker.FindNext %v to %v", dest, rt.NextHop)
 		}
-		return rt.NextHop.To4()
+		if len(dest) == net.IPv4len {
+			return rt.NextHop.To4()
+		}
+		return rt.NextHop.To16()
 	}
 	return dest
 }
@@ -1204,26 +1877,42 @@ func (w *Worker) OnIpAddr(s *SocketWorker, n *models.Network) error {
 		w.out.Debug("Worker.OnIpAddr: %s noChanged", addr)
 		return nil
 	}
-	w.out.Cmd("Worker.OnIpAddr: %s", addr)
+	w.out.Cmd("Worker.OnIpAddr: %s %s", addr, n.IfAddr6)
 	w.out.Cmd("Worker.OnIpAddr: %s", n.Routes)
-	prefix := libol.Netmask2Len(n.Netmask)
-	ipStr := fmt.Sprintf("%s/%d", n.IfAddr, prefix)
-	w.tapWorker.OnIpAddr(ipStr)
-	if w.listener.AddAddr != nil {
-		_ = w.listener.AddAddr(ipStr)
+	if n.IfAddr6 != "" {
+		w.tapWorker.OnIpAddr(n.IfAddr6)
+	}
+	if n.IfAddr != "" {
+		prefix := libol.Netmask2Len(n.Netmask)
+		ipStr := fmt.Sprintf("%s/%d", n.IfAddr, prefix)
+		w.tapWorker.OnIpAddr(ipStr)
+		if w.listener.AddAddr != nil {
+			_ = w.listener.AddAddr(ipStr)
+		}
+	}
+	if n.IfAddr6 != "" && w.listener.AddAddr != nil {
+		_ = w.listener.AddAddr(n.IfAddr6)
 	}
 	if w.listener.AddRoutes != nil {
 		_ = w.listener.AddRoutes(n.Routes)
 	}
 	w.network = n
 	// update routes
-	ip := net.ParseIP(w.network.IfAddr)
-	m := net.IPMask(net.ParseIP(w.network.Netmask).To4())
-	w.routes = append(w.routes, PrefixRule{
-		Type:        0x00,
-		Destination: net.IPNet{IP: ip.Mask(m), Mask: m},
-		NextHop:     libol.EthZero,
-	})
+	if ip := net.ParseIP(w.network.IfAddr); ip != nil {
+		m := net.IPMask(net.ParseIP(w.network.Netmask).To4())
+		w.routes = append(w.routes, PrefixRule{
+			Type:        0x00,
+			Destination: net.IPNet{IP: ip.Mask(m), Mask: m},
+			NextHop:     libol.EthZero,
+		})
+	}
+	if _, inet, err := net.ParseCIDR(w.network.IfAddr6); err == nil {
+		w.routes = append(w.routes, PrefixRule{
+			Type:        0x00,
+			Destination: *inet,
+			NextHop:     libol.EthZero,
+		})
+	}
 	for _, rt := range n.Routes {
 		_, dest, err := net.ParseCIDR(rt.Prefix)
 		if err != nil {
@@ -1247,9 +1936,14 @@ func (w *Worker) FreeIpAddr() {
 		_ = w.listener.DelRoutes(w.network.Routes)
 	}
 	if w.listener.DelAddr != nil {
-		prefix := libol.Netmask2Len(w.network.Netmask)
-		ipStr := fmt.Sprintf("%s/%d", w.network.IfAddr, prefix)
-		_ = w.listener.DelAddr(ipStr)
+		if w.network.IfAddr != "" {
+			prefix := libol.Netmask2Len(w.network.Netmask)
+			ipStr := fmt.Sprintf("%s/%d", w.network.IfAddr, prefix)
+			_ = w.listener.DelAddr(ipStr)
+		}
+		if w.network.IfAddr6 != "" {
+			_ = w.listener.DelAddr(w.network.IfAddr6)
+		}
 	}
 	w.network = nil
 	w.routes = make([]PrefixRule, 0, 32)
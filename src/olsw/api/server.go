@@ -1,6 +1,7 @@
 package api
 
 import (
+	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/gorilla/mux"
 	"net/http"
 )
@@ -14,24 +15,53 @@ func (l Server) Router(router *mux.Router) {
 	router.HandleFunc("/api/server/{id}", l.List).Methods("GET")
 }
 
+type listener struct {
+	Protocol  string           `json:"protocol"`
+	Address   string           `json:"address"`
+	Total     int              `json:"total"`
+	Statistic map[string]int64 `json:"statistic"`
+}
+
 func (l Server) List(w http.ResponseWriter, r *http.Request) {
-	server := l.Switcher.Server()
 	data := &struct {
 		UpTime     int64            `json:"uptime"`
 		Total      int              `json:"total"`
 		Statistic  map[string]int64 `json:"statistic"`
 		Connection []interface{}    `json:"connection"`
+		Listener   []listener       `json:"listener"`
 	}{
 		UpTime:     l.Switcher.UpTime(),
-		Statistic:  server.Statistics(),
+		Statistic:  make(map[string]int64, 32),
 		Connection: make([]interface{}, 0, 1024),
-		Total:      server.TotalClient(),
+		Listener:   make([]listener, 0, 4),
+	}
+	listeners := l.Switcher.Config().GetListeners()
+	for i, server := range l.Switcher.Servers() {
+		sts := server.Statistics()
+		for k, v := range sts {
+			data.Statistic[k] += v
+		}
+		data.Total += server.TotalClient()
+		obj := listener{
+			Address:   server.Address(),
+			Total:     server.TotalClient(),
+			Statistic: sts,
+		}
+		if i < len(listeners) {
+			obj.Protocol = listeners[i].Protocol
+		}
+		data.Listener = append(data.Listener, obj)
+		l.addClient(server, &data.Connection)
 	}
+	ResponseJson(w, data)
+}
+
+func (l Server) addClient(server libol.SocketServer, connection *[]interface{}) {
 	for u := range server.ListClient() {
 		if u == nil {
 			break
 		}
-		data.Connection = append(data.Connection, &struct {
+		*connection = append(*connection, &struct {
 			UpTime     int64            `json:"uptime"`
 			LocalAddr  string           `json:"localAddr"`
 			RemoteAddr string           `json:"remoteAddr"`
@@ -43,5 +73,4 @@ func (l Server) List(w http.ResponseWriter, r *http.Request) {
 			Statistic:  u.Statistics(),
 		})
 	}
-	ResponseJson(w, data)
 }
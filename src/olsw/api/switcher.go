@@ -13,7 +13,7 @@ type Switcher interface {
 	AddLink(tenant string, c *config.Point)
 	DelLink(tenant, addr string)
 	Config() *config.Switch
-	Server() libol.SocketServer
+	Servers() []libol.SocketServer
 }
 
 func NewWorkerSchema(s Switcher) schema.Worker {
@@ -5,19 +5,33 @@ import (
 	"github.com/danieldin95/openlan-go/src/cli/config"
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/models"
+	"github.com/danieldin95/openlan-go/src/network"
 	"github.com/danieldin95/openlan-go/src/olsw/storage"
 )
 
 type Access struct {
-	success int
-	failed  int
-	master  Master
+	success    int
+	failed     int
+	master     Master
+	clientAuth string   // cert or both if verify certificate of TLS client.
+	compress   string   // accepted if supported by point.
+	caps       []string // capabilities of switch.
 }
 
 func NewAccess(m Master, c config.Switch) *Access {
-	return &Access{
-		master: m,
+	a := &Access{
+		master:   m,
+		compress: c.Compress,
+		caps:     c.Capabilities(),
 	}
+	if c.Cert != nil && c.Cert.ClientCa != "" {
+		for _, l := range c.GetListeners() {
+			if l.Protocol == "tls" || l.Protocol == "wss" {
+				a.clientAuth = c.Cert.ClientAuth
+			}
+		}
+	}
+	return a
 }
 
 func (p *Access) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
@@ -30,15 +44,42 @@ func (p *Access) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 		out.Debug("Access.OnFrame: %s", action)
 		switch action {
 		case libol.LoginReq:
-			if err := p.handleLogin(client, params); err != nil {
+			user, err := p.handleLogin(client, params)
+			if err != nil {
 				out.Error("Access.OnFrame: %s", err)
-				m := libol.NewControlFrame(libol.LoginResp, []byte(err.Error()))
+				m := libol.NewControlFrame(libol.LoginResp, toLogin(err).EncodeTo(protocolOf(params)))
 				_ = client.WriteMsg(m)
 				//client.Close()
 				return err
 			}
-			m := libol.NewControlFrame(libol.LoginResp, []byte("okay"))
+			resp := models.NewLogin()
+			resp.Capability = p.caps
+			algo := p.negotiate(user)
+			resp.Compress = algo
+			m := libol.NewControlFrame(libol.LoginResp, resp.EncodeTo(protocolOf(params)))
 			_ = client.WriteMsg(m)
+			if user != nil {
+				client.SetCapability(libol.Negotiate(p.caps, user.Capability))
+			}
+			if algo != "" {
+				// frames after response are compressed.
+				_ = client.SetCompress(algo)
+			}
+		case libol.ProbeReq:
+			// reply probe of latency, and point not need to login.
+			m := libol.NewControlFrame(libol.ProbeResp, params)
+			_ = client.WriteMsg(m)
+		case libol.JoinReq:
+			resp, err := p.handleJoin(client, params)
+			if err != nil {
+				out.Error("Access.OnFrame: %s", err)
+				resp = toLogin(err)
+			}
+			m := libol.NewControlFrame(libol.JoinResp, resp.Encode())
+			_ = client.WriteMsg(m)
+			if err != nil {
+				return err
+			}
 		}
 		//If instruct is not login and already auth, continue to process.
 		if client.Have(libol.ClAuth) {
@@ -53,19 +94,69 @@ func (p *Access) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 	return nil
 }
 
-func (p *Access) handleLogin(client libol.SocketClient, data []byte) error {
+func toLogin(err error) *models.Login {
+	if resp, ok := err.(*models.Login); ok {
+		return resp
+	}
+	return models.NewLoginErr(models.LoginFailed, err.Error())
+}
+
+// protocolOf returns protocol in request of login, and 0 is older point.
+func protocolOf(data []byte) int {
+	user := &models.User{}
+	if err := json.Unmarshal(data, user); err != nil {
+		return 0
+	}
+	return user.Protocol
+}
+
+// negotiate returns compress algorithm accepted by both sides.
+func (p *Access) negotiate(user *models.User) string {
+	if user == nil || p.compress == "" || libol.GetCompressor(p.compress) == nil {
+		return ""
+	}
+	for _, algo := range user.Compress {
+		if algo == p.compress {
+			return algo
+		}
+	}
+	return ""
+}
+
+func (p *Access) handleLogin(client libol.SocketClient, data []byte) (*models.User, error) {
 	out := client.Out()
 	out.Debug("Access.handleLogin: %s", data)
 	if client.Have(libol.ClAuth) {
 		out.Warn("Access.handleLogin: already auth")
-		return nil
+		return nil, nil
 	}
 	user := &models.User{}
 	if err := json.Unmarshal(data, user); err != nil {
-		return libol.NewErr("Invalid json data.")
+		return nil, models.NewLoginErr(models.LoginInvalid, "Invalid json data.")
 	}
 	user.Update()
+	if user.Protocol < libol.MinProtocol {
+		p.failed++
+		client.SetStatus(libol.ClUnAuth)
+		return nil, models.NewLoginErr(models.LoginTooOld,
+			"Protocol %d older than %d.", user.Protocol, libol.MinProtocol)
+	}
+	auth := p.authOf(client)
+	if auth != "" {
+		if err := p.checkCert(client, user); err != nil {
+			p.failed++
+			client.SetStatus(libol.ClUnAuth)
+			return nil, err
+		}
+	}
 	out.Info("Access.handleLogin: %s on %s", user.Id(), user.Alias)
+	if auth == "cert" {
+		p.success++
+		client.SetStatus(libol.ClAuth)
+		out.Info("Access.handleLogin: success by certificate")
+		_ = p.onAuth(client, user)
+		return user, nil
+	}
 	nowUser := storage.User.Get(user.Id())
 	if nowUser != nil {
 		if nowUser.Password == user.Password {
@@ -73,12 +164,112 @@ func (p *Access) handleLogin(client libol.SocketClient, data []byte) error {
 			client.SetStatus(libol.ClAuth)
 			out.Info("Access.handleLogin: success")
 			_ = p.onAuth(client, user)
-			return nil
+			return user, nil
 		}
 	}
 	p.failed++
 	client.SetStatus(libol.ClUnAuth)
-	return libol.NewErr("Auth failed.")
+	return nil, models.NewLoginErr(models.LoginFailed, "Auth failed.")
+}
+
+// handleJoin joins other network by channel on connection logged in, and
+// frames of the channel are from or to a new device of the network.
+func (p *Access) handleJoin(client libol.SocketClient, data []byte) (*models.Login, error) {
+	out := client.Out()
+	out.Debug("Access.handleJoin: %s", data)
+	point, ok := client.Private().(*models.Point)
+	if !ok || !client.Have(libol.ClAuth) {
+		return nil, models.NewLoginErr(models.LoginFailed, "Not login.")
+	}
+	user := &models.User{}
+	if err := json.Unmarshal(data, user); err != nil {
+		return nil, models.NewLoginErr(models.LoginInvalid, "Invalid json data.")
+	}
+	user.Update()
+	if err := p.join(client, point, user); err != nil {
+		resp := toLogin(err)
+		resp.Channel = user.Channel
+		return nil, resp
+	}
+	resp := models.NewLogin()
+	resp.Channel = user.Channel
+	return resp, nil
+}
+
+func (p *Access) join(client libol.SocketClient, point *models.Point, user *models.User) error {
+	out := client.Out()
+	ch := user.Channel
+	if ch == 0 || user.Network == point.Network {
+		return models.NewLoginErr(models.LoginInvalid, "Invalid channel %d.", ch)
+	}
+	auth := p.authOf(client)
+	if auth != "" {
+		if err := p.checkCert(client, user); err != nil {
+			return err
+		}
+	}
+	if auth != "cert" {
+		nowUser := storage.User.Get(user.Id())
+		if nowUser == nil || nowUser.Password != user.Password {
+			return models.NewLoginErr(models.LoginFailed, "Auth failed.")
+		}
+	}
+	dev, err := p.master.NewTap(user.Network)
+	if err != nil {
+		return err
+	}
+	out.Info("Access.join: %s by channel %d on >>> %s <<<", user.Id(), ch, dev.Name())
+	if older := point.Join(ch, dev); older != nil {
+		_ = older.Close()
+	}
+	libol.Go(func() {
+		p.master.ReadTap(dev, func(f *libol.FrameMessage) error {
+			f.SetChannel(ch)
+			libol.ClampMss(f.Frame()[:f.Size()], client.Mtu())
+			if err := client.WriteMsg(f); err != nil {
+				p.master.OffClient(client)
+				return err
+			}
+			return nil
+		})
+	})
+	return nil
+}
+
+// authOf returns mode of client auth, and the client not by TLS has no
+// certificate to verify, so it is authenticated by password.
+func (p *Access) authOf(client libol.SocketClient) string {
+	if client.PeerCert() == nil {
+		return ""
+	}
+	return p.clientAuth
+}
+
+// checkCert maps CN or SAN of certificate to user@network, and
+// uses the first one if name of user not given.
+func (p *Access) checkCert(client libol.SocketClient, user *models.User) error {
+	cert := client.PeerCert()
+	if cert == nil {
+		return libol.NewErr("Certificate notFound.")
+	}
+	names := append([]string{cert.Subject.CommonName}, cert.EmailAddresses...)
+	names = append(names, cert.DNSNames...)
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		owner := &models.User{Name: name}
+		owner.Update()
+		if user.Name == "" {
+			user.Name = owner.Name
+			user.Network = owner.Network
+			return nil
+		}
+		if owner.Id() == user.Id() {
+			return nil
+		}
+	}
+	return libol.NewErr("Certificate notMatched %s.", user.Id())
 }
 
 func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
@@ -87,22 +278,18 @@ func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
 		return libol.NewErr("not auth.")
 	}
 	out.Info("Access.onAuth")
+	if user.Bond != "" {
+		return p.onBond(client, user)
+	}
 	dev, err := p.master.NewTap(user.Network)
 	if err != nil {
 		return err
 	}
 	out.Info("Access.onAuth: on >>> %s <<<", dev.Name())
-	m := models.NewPoint(client, dev)
-	m.SetUser(user)
-	// free point has same uuid.
-	if om := storage.Point.GetByUUID(m.UUID); om != nil {
-		out.Info("Access.onAuth: OffClient %s", om.Client)
-		p.master.OffClient(om.Client)
-	}
-	client.SetPrivate(m)
-	storage.Point.Add(m)
+	p.addPoint(client, user, dev)
 	libol.Go(func() {
 		p.master.ReadTap(dev, func(f *libol.FrameMessage) error {
+			libol.ClampMss(f.Frame()[:f.Size()], client.Mtu())
 			if err := client.WriteMsg(f); err != nil {
 				p.master.OffClient(client)
 				return err
@@ -113,6 +300,52 @@ func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
 	return nil
 }
 
+// onBond shares device among links of point, and frames of the device are
+// sent by link of their flow.
+func (p *Access) onBond(client libol.SocketClient, user *models.User) error {
+	out := client.Out()
+	b, err := storage.Point.Bond(user.Bond, user.Id(), client, func() (*models.Bond, error) {
+		dev, err := p.master.NewTap(user.Network)
+		if err != nil {
+			return nil, err
+		}
+		b := models.NewBond(user.Bond, user.Id(), dev)
+		libol.Go(func() {
+			p.master.ReadTap(dev, func(f *libol.FrameMessage) error {
+				link := b.Link(f.Frame()[:f.Size()])
+				if link == nil { // closed by last link soon.
+					return nil
+				}
+				libol.ClampMss(f.Frame()[:f.Size()], link.Mtu())
+				if err := link.WriteMsg(f); err != nil {
+					p.master.OffClient(link)
+				}
+				return nil
+			})
+		})
+		return b, nil
+	})
+	if err != nil {
+		return err
+	}
+	out.Info("Access.onBond: %s on >>> %s <<<", b.ID, b.Device.Name())
+	p.addPoint(client, user, b.Device)
+	return nil
+}
+
+// addPoint saves point of client, and frees older one has same uuid.
+func (p *Access) addPoint(client libol.SocketClient, user *models.User, dev network.Taper) {
+	out := client.Out()
+	m := models.NewPoint(client, dev)
+	m.SetUser(user)
+	if om := storage.Point.GetByUUID(m.UUID); om != nil {
+		out.Info("Access.addPoint: OffClient %s", om.Client)
+		p.master.OffClient(om.Client)
+	}
+	client.SetPrivate(m)
+	storage.Point.Add(m)
+}
+
 func (p *Access) Stats() (success, failed int) {
 	return p.success, p.failed
 }
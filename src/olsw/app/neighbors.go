@@ -31,13 +31,28 @@ func (e *Neighbors) OnFrame(client libol.SocketClient, frame *libol.FrameMessage
 		libol.Warn("Neighbors.OnFrame %s", err)
 		return err
 	}
-	if eth := proto.Eth; !eth.IsArp() {
-		return nil
-	}
-	arp := proto.Arp
-	if arp.IsIP4() && (arp.IsReply() || arp.IsRequest()) {
-		n := models.NewNeighbor(arp.SHwAddr, arp.SIpAddr, client)
-		e.AddNeighbor(n, client)
+	if arp := proto.Arp; arp != nil {
+		if arp.IsIP4() && (arp.IsReply() || arp.IsRequest()) {
+			n := models.NewNeighbor(arp.SHwAddr, arp.SIpAddr, client)
+			e.AddNeighbor(n, client)
+		}
+	} else if ndp := proto.Ndp; ndp != nil {
+		switch proto.Icmp6.Type {
+		case libol.Icmp6NeighSolicit:
+			// source is unspecified for duplicate address detection.
+			source := net.IP(proto.Ip6.Source)
+			if ndp.Option == libol.NdpSrcLinkAddr && !source.IsUnspecified() {
+				n := models.NewNeighbor(ndp.HwAddr, source, client)
+				e.AddNeighbor(n, client)
+			}
+		case libol.Icmp6NeighAdvert:
+			hwAddr := proto.Eth.Src
+			if ndp.Option == libol.NdpDstLinkAddr {
+				hwAddr = ndp.HwAddr
+			}
+			n := models.NewNeighbor(hwAddr, ndp.Target, client)
+			e.AddNeighbor(n, client)
+		}
 	}
 	return nil
 }
@@ -40,12 +40,19 @@ func (o *Online) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 		libol.Warn("Online.OnFrame %s", err)
 		return err
 	}
-	if proto.Ip4 != nil {
-		ip := proto.Ip4
-		line := models.NewLine(libol.EthIp4)
+	var line *models.Line
+	if ip := proto.Ip4; ip != nil {
+		line = models.NewLine(libol.EthIp4)
 		line.IpSource = ip.Source
 		line.IpDest = ip.Destination
 		line.IpProtocol = ip.Protocol
+	} else if ip := proto.Ip6; ip != nil {
+		line = models.NewLine(libol.EthIp6)
+		line.IpSource = ip.Source
+		line.IpDest = ip.Destination
+		line.IpProtocol = ip.Protocol
+	}
+	if line != nil {
 		if proto.Tcp != nil {
 			tcp := proto.Tcp
 			line.PortDest = tcp.Destination
@@ -7,6 +7,8 @@ import (
 	"github.com/danieldin95/openlan-go/src/models"
 	"github.com/danieldin95/openlan-go/src/olsw/schema"
 	"github.com/danieldin95/openlan-go/src/olsw/storage"
+	"net"
+	"strconv"
 	"strings"
 )
 
@@ -39,7 +41,11 @@ func (r *Request) OnFrame(client libol.SocketClient, frame *libol.FrameMessage)
 		r.onIpAddr(client, body)
 	case libol.LeftReq:
 		r.onLeave(client, body)
-	case libol.LoginReq:
+	case libol.MtuReq:
+		r.onMtu(client, body)
+	case libol.LoginReq, libol.JoinReq, libol.ProbeReq:
+		out.Debug("Request.OnFrame %s: %s", action, body)
+	case libol.PongResp:
 		out.Debug("Request.OnFrame %s: %s", action, body)
 	default:
 		r.onDefault(client, body)
@@ -52,6 +58,35 @@ func (r *Request) onDefault(client libol.SocketClient, data []byte) {
 	_ = client.WriteMsg(m)
 }
 
+// onMtu replies probe of path MTU, and saves MTU reported by point not
+// less than MtuMin and not larger than MTU of device.
+func (r *Request) onMtu(client libol.SocketClient, data []byte) {
+	out := client.Out()
+	probe, err := libol.DecodeMtuProbe(data)
+	if err != nil {
+		out.Error("Request.onMtu: %s", err)
+		return
+	}
+	if probe.Mtu > 0 {
+		mtu := probe.Mtu
+		if mtu < libol.MtuMin {
+			mtu = libol.MtuMin
+		}
+		if p, ok := client.Private().(*models.Point); ok && p.Device != nil {
+			if max := p.Device.Mtu(); max > 0 && mtu > max {
+				mtu = max
+			}
+		}
+		out.Info("Request.onMtu: %d by %d", mtu, probe.Mtu)
+		client.SetMtu(mtu)
+		return
+	}
+	if body, err := json.Marshal(&libol.MtuProbe{Size: probe.Size}); err == nil {
+		m := libol.NewControlFrame(libol.MtuResp, body)
+		_ = client.WriteMsg(m)
+	}
+}
+
 func (r *Request) onNeighbor(client libol.SocketClient, data []byte) {
 	resp := make([]schema.Neighbor, 0, 32)
 	for obj := range storage.Neighbor.List() {
@@ -96,9 +131,22 @@ func (r *Request) getLease(ifAddr string, p *models.Point, n *models.Network) *s
 	if lease != nil {
 		lease.Network = network
 		lease.Client = p.Client.String()
+		storage.Network.NewLease6(lease, network)
 	}
 	return lease
 }
+
+func (r *Request) getIfAddr6(lease *schema.Lease, n *models.Network) string {
+	if lease == nil || lease.Address6 == "" {
+		return ""
+	}
+	_, inet, err := net.ParseCIDR(n.Prefix6)
+	if err != nil {
+		return ""
+	}
+	ones, _ := inet.Mask.Size()
+	return lease.Address6 + "/" + strconv.Itoa(ones)
+}
 func (r *Request) onIpAddr(client libol.SocketClient, data []byte) {
 	var resp *models.Network
 	out := client.Out()
@@ -134,12 +182,18 @@ func (r *Request) onIpAddr(client libol.SocketClient, data []byte) {
 				IpStart: n.IpStart,
 				IpEnd:   n.IpEnd,
 				Netmask: n.Netmask,
+				IfAddr6: r.getIfAddr6(lease, n),
+				Prefix6: n.Prefix6,
 				Routes:  n.Routes,
 			}
 		}
 		// get release failed.
 	} else {
 		resp = recv
+		if resp.IfAddr6 == "" {
+			resp.IfAddr6 = r.getIfAddr6(lease, n)
+			resp.Prefix6 = n.Prefix6
+		}
 	}
 	if resp != nil {
 		out.Cmd("Request.onIpAddr: resp %s", resp)
@@ -147,7 +201,7 @@ func (r *Request) onIpAddr(client libol.SocketClient, data []byte) {
 			m := libol.NewControlFrame(libol.IpAddrResp, respStr)
 			_ = client.WriteMsg(m)
 		}
-		out.Info("Request.onIpAddr: %s", resp.IfAddr)
+		out.Info("Request.onIpAddr: %s %s", resp.IfAddr, resp.IfAddr6)
 	} else {
 		out.Error("Request.onIpAddr: %s no free address", recv.Name)
 		m := libol.NewControlFrame(libol.IpAddrResp, []byte("no free address"))
@@ -11,6 +11,7 @@ import (
 	"github.com/danieldin95/openlan-go/src/olsw/storage"
 	"github.com/gorilla/mux"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
@@ -30,6 +31,7 @@ type Http struct {
 	keyFile    string
 	pubDir     string
 	router     *mux.Router
+	mounts     map[string]http.Handler // served without token.
 }
 
 func NewHttp(switcher api.Switcher, c config.Switch) (h *Http) {
@@ -40,6 +42,7 @@ func NewHttp(switcher api.Switcher, c config.Switch) (h *Http) {
 		crtFile:   c.Cert.CrtFile,
 		keyFile:   c.Cert.KeyFile,
 		pubDir:    c.Http.Public,
+		mounts:    make(map[string]http.Handler, 4),
 	}
 
 	return
@@ -76,9 +79,18 @@ func (h *Http) PProf(r *mux.Router) {
 	}
 }
 
+// Mount serves handler on path, and which has its own authentication like
+// websocket of points.
+func (h *Http) Mount(path string, handler http.Handler) {
+	libol.Info("Http.Mount %s", path)
+	h.mounts[path] = handler
+}
+
 func (h *Http) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if h.IsAuth(w, r) {
+		if _, ok := h.mounts[r.URL.Path]; ok {
+			next.ServeHTTP(w, r)
+		} else if h.IsAuth(w, r) {
 			next.ServeHTTP(w, r)
 		} else {
 			w.Header().Set("WWW-Authenticate", "Basic")
@@ -118,6 +130,9 @@ func (h *Http) LoadRouter() {
 	router.HandleFunc("/favicon.ico", h.PubFile)
 
 	h.PProf(router)
+	for path, handler := range h.mounts {
+		router.Handle(path, handler)
+	}
 	router.HandleFunc("/api/index", h.GetIndex).Methods("GET")
 	router.HandleFunc("/api/config", func(w http.ResponseWriter, r *http.Request) {
 		format := api.GetQueryOne(r, "format")
@@ -272,6 +287,7 @@ func (h *Http) ParseFiles(w http.ResponseWriter, name string, data interface{})
 		"prettyTime":  libol.PrettyTime,
 		"prettyBytes": libol.PrettyBytes,
 		"getIpAddr":   libol.GetIPAddr,
+		"hostPort":    net.JoinHostPort,
 	}).ParseFiles(name)
 	if err != nil {
 		_, _ = fmt.Fprintf(w, "template.ParseFiles %s", err)
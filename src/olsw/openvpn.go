@@ -5,6 +5,7 @@ import (
 	"github.com/danieldin95/openlan-go/src/cli/config"
 	"github.com/danieldin95/openlan-go/src/libol"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"strings"
@@ -85,7 +86,7 @@ verb 3
 
 func NewOpenVpnDataFromConf(cfg *config.OpenVPN) *OpenVPNData {
 	data := &OpenVPNData{
-		Local:    strings.SplitN(cfg.Listen, ":", 2)[0],
+		Local:    libol.GetIPAddr(cfg.Listen),
 		Ca:       cfg.RootCa,
 		Cert:     cfg.ServerCrt,
 		Key:      cfg.ServerKey,
@@ -98,8 +99,8 @@ func NewOpenVpnDataFromConf(cfg *config.OpenVPN) *OpenVPNData {
 	}
 	addr, _ := libol.IPNetwork(cfg.Subnet)
 	data.Server = strings.ReplaceAll(addr, "/", " ")
-	if strings.Contains(cfg.Listen, ":") {
-		data.Port = strings.SplitN(cfg.Listen, ":", 2)[1]
+	if _, port, err := net.SplitHostPort(cfg.Listen); err == nil {
+		data.Port = port
 	}
 	for _, rt := range cfg.Routes {
 		if addr, err := libol.IPNetwork(rt); err == nil {
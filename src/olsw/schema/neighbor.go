@@ -5,6 +5,7 @@ type Neighbor struct {
 	UUID    string `json:"uuid"`
 	HwAddr  string `json:"ethernet"`
 	IpAddr  string `json:"address"`
+	Family  string `json:"family"`
 	Client  string `json:"client"`
 	Switch  string `json:"switch"`
 	Network string `json:"network"`
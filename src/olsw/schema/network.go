@@ -1,12 +1,13 @@
 package schema
 
 type Lease struct {
-	Address string `json:"address"`
-	UUID    string `json:"uuid"`
-	Alias   string `json:"alias"`
-	Client  string `json:"client"`
-	Type    string `json:"type"`
-	Network string `json:"network"`
+	Address  string `json:"address"`
+	Address6 string `json:"address6,omitempty"`
+	UUID     string `json:"uuid"`
+	Alias    string `json:"alias"`
+	Client   string `json:"client"`
+	Type     string `json:"type"`
+	Network  string `json:"network"`
 }
 
 type PrefixRoute struct {
@@ -19,5 +20,7 @@ type Network struct {
 	IpStart string        `json:"ipStart"`
 	IpEnd   string        `json:"ipEnd"`
 	Netmask string        `json:"netmask"`
+	IfAddr6 string        `json:"ifAddr6,omitempty"`
+	Prefix6 string        `json:"prefix6,omitempty"`
 	Routes  []PrefixRoute `json:"routes"`
 }
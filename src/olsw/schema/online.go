@@ -4,6 +4,7 @@ type OnLine struct {
 	HitTime    int64  `json:"hittime"`
 	UpTime     int64  `json:"uptime"`
 	EthType    uint16 `json:"ethType"`
+	Family     string `json:"family"`
 	IpSource   string `json:"ipSource"`
 	IpDest     string `json:"ipDestination"`
 	IpProto    string `json:"ipProtocol"`
@@ -1,18 +1,21 @@
 package schema
 
 type Point struct {
-	Uptime    int64  `json:"uptime"`
-	UUID      string `json:"uuid"`
-	Network   string `json:"network"`
-	User      string `json:"user"`
-	Alias     string `json:"alias"`
-	Address   string `json:"server"`
-	Switch    string `json:"switch,omitempty"`
-	Device    string `json:"device"`
-	RxBytes   int64  `json:"rxBytes"`
-	TxBytes   int64  `json:"txBytes"`
-	ErrPkt    int64  `json:"errors"`
-	State     string `json:"state"`
-	AliveTime int64  `json:"aliveTime"`
-	System    string `json:"system"`
+	Uptime     int64    `json:"uptime"`
+	UUID       string   `json:"uuid"`
+	Network    string   `json:"network"`
+	User       string   `json:"user"`
+	Alias      string   `json:"alias"`
+	Address    string   `json:"server"`
+	Switch     string   `json:"switch,omitempty"`
+	Device     string   `json:"device"`
+	RxBytes    int64    `json:"rxBytes"`
+	TxBytes    int64    `json:"txBytes"`
+	ErrPkt     int64    `json:"errors"`
+	State      string   `json:"state"`
+	AliveTime  int64    `json:"aliveTime"`
+	System     string   `json:"system"`
+	Protocol   int      `json:"protocol"`
+	Capability []string `json:"capability,omitempty"`
+	Mtu        int      `json:"mtu,omitempty"` // path MTU measured.
 }
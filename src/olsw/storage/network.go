@@ -6,8 +6,11 @@ import (
 	"github.com/danieldin95/openlan-go/src/models"
 	"github.com/danieldin95/openlan-go/src/olsw/schema"
 	"net"
+	"strings"
 )
 
+const LeaseMax6 = 0x10000
+
 type network struct {
 	Networks *libol.SafeStrMap
 	UUID     *libol.SafeStrMap // TODO with network
@@ -82,6 +85,38 @@ func (w *network) allocLease(sAddr, eAddr string) string {
 	return ""
 }
 
+// allocLease6 allocates address from host part of prefix, and ::1 and
+// address of bridge are reserved for gateway.
+func (w *network) allocLease6(prefix, ifAddr string) string {
+	_, inet, err := net.ParseCIDR(prefix)
+	if err != nil || inet.IP.To4() != nil {
+		return ""
+	}
+	reserved := ""
+	if ip := net.ParseIP(strings.SplitN(ifAddr, "/", 2)[0]); ip != nil {
+		reserved = ip.String()
+	}
+	ones, bits := inet.Mask.Size()
+	size := uint64(LeaseMax6)
+	if bits-ones < 16 {
+		size = uint64(1) << uint(bits-ones)
+	}
+	start := binary.BigEndian.Uint64(inet.IP[8:16])
+	for i := uint64(2); i < size; i++ {
+		tmp := make([]byte, 16)
+		copy(tmp[:8], inet.IP[:8])
+		binary.BigEndian.PutUint64(tmp[8:16], start+i)
+		tmpStr := net.IP(tmp).String()
+		if tmpStr == reserved {
+			continue
+		}
+		if _, ok := w.Addr.GetEx(tmpStr); !ok {
+			return tmpStr
+		}
+	}
+	return ""
+}
+
 func (w *network) NewLease(uuid, network string) *schema.Lease {
 	n := w.Get(network)
 	if n == nil || uuid == "" {
@@ -92,11 +127,38 @@ func (w *network) NewLease(uuid, network string) *schema.Lease {
 		return l // how to resolve conflict with new point?.
 	}
 	ipStr := w.allocLease(n.IpStart, n.IpEnd)
-	if ipStr == "" {
+	ip6Str := w.allocLease6(n.Prefix6, n.IfAddr6)
+	if ipStr == "" && ip6Str == "" {
 		return nil
 	}
-	w.AddLease(uuid, ipStr)
-	return w.GetLease(uuid)
+	l := &schema.Lease{
+		UUID:     uuid,
+		Alias:    uuid,
+		Address:  ipStr,
+		Address6: ip6Str,
+	}
+	libol.Info("network.NewLease %s %s %s", uuid, ipStr, ip6Str)
+	_ = w.UUID.Set(uuid, l)
+	if ipStr != "" {
+		_ = w.Addr.Set(ipStr, l)
+	}
+	if ip6Str != "" {
+		_ = w.Addr.Set(ip6Str, l)
+	}
+	return l
+}
+
+// NewLease6 allocates IPv6 address for lease without it.
+func (w *network) NewLease6(l *schema.Lease, network string) {
+	n := w.Get(network)
+	if n == nil || l.Address6 != "" {
+		return
+	}
+	if ip6Str := w.allocLease6(n.Prefix6, n.IfAddr6); ip6Str != "" {
+		libol.Info("network.NewLease6 %s %s", l.UUID, ip6Str)
+		l.Address6 = ip6Str
+		_ = w.Addr.Set(ip6Str, l)
+	}
 }
 
 func (w *network) GetLease(uuid string) *schema.Lease {
@@ -134,10 +196,13 @@ func (w *network) DelLease(uuid string) {
 	if obj, ok := w.UUID.GetEx(uuid); ok {
 		l := obj.(*schema.Lease)
 		addr := l.Address
-		libol.Info("network.DelLease %s %s", uuid, addr)
+		libol.Info("network.DelLease %s %s %s", uuid, addr, l.Address6)
 		if l.Type != "static" {
 			w.UUID.Del(uuid)
 			w.Addr.Del(addr)
+			if l.Address6 != "" {
+				w.Addr.Del(l.Address6)
+			}
 		}
 	}
 }
@@ -3,18 +3,22 @@ package storage
 import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/models"
+	"sync"
 )
 
 type point struct {
 	Clients  *libol.SafeStrMap
 	UUIDAddr *libol.SafeStrStr
 	AddrUUID *libol.SafeStrStr
+	lock     sync.Mutex
+	bonds    map[string]*models.Bond
 }
 
 var Point = point{
 	Clients:  libol.NewSafeStrMap(1024),
 	UUIDAddr: libol.NewSafeStrStr(1024),
 	AddrUUID: libol.NewSafeStrStr(1024),
+	bonds:    make(map[string]*models.Bond, 32),
 }
 
 func (p *point) Init(size int) {
@@ -51,11 +55,47 @@ func (p *point) GetAddr(uuid string) string {
 	return p.UUIDAddr.Get(uuid)
 }
 
+// Bond adds client to bond of id, and creates it by newBond if not found.
+func (p *point) Bond(id, user string, client libol.SocketClient, newBond func() (*models.Bond, error)) (*models.Bond, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	b, ok := p.bonds[id]
+	if !ok {
+		var err error
+		if b, err = newBond(); err != nil {
+			return nil, err
+		}
+		p.bonds[id] = b
+	} else if b.User != user {
+		return nil, libol.NewErr("bond %s notRight", id)
+	}
+	b.Add(client)
+	return b, nil
+}
+
+// closeDevice closes device of point, and device of bond is closed by
+// its last link.
+func (p *point) closeDevice(m *models.Point) {
+	if m.Bond == "" {
+		if m.Device != nil {
+			_ = m.Device.Close()
+		}
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if b, ok := p.bonds[m.Bond]; ok && b.Del(m.Client) == 0 {
+		delete(p.bonds, m.Bond)
+		_ = b.Device.Close()
+	}
+}
+
 func (p *point) Del(addr string) {
 	if v := p.Clients.Get(addr); v != nil {
 		m := v.(*models.Point)
-		if m.Device != nil {
-			_ = m.Device.Close()
+		p.closeDevice(m)
+		for _, dev := range m.Leave() {
+			_ = dev.Close()
 		}
 		if p.UUIDAddr.Get(m.UUID) == addr { // not has newer
 			p.UUIDAddr.Del(m.UUID)
@@ -14,61 +14,84 @@ import (
 	"time"
 )
 
-func GetSocketServer(s config.Switch) libol.SocketServer {
-	switch s.Protocol {
+func GetSocketServer(s config.Switch, l config.Listener) libol.SocketServer {
+	batch, delay := s.Batch.Get()
+	aead := s.GetAead()
+	switch l.Protocol {
 	case "kcp":
 		c := &libol.KcpConfig{
 			Block:   config.GetBlock(s.Crypt),
+			Aead:    aead,
 			Timeout: time.Duration(s.Timeout) * time.Second,
 		}
-		return libol.NewKcpServer(s.Listen, c)
+		return libol.NewKcpServer(l.Listen, c)
 	case "tcp":
 		c := &libol.TcpConfig{
 			Block:   config.GetBlock(s.Crypt),
+			Aead:    aead,
 			Timeout: time.Duration(s.Timeout) * time.Second,
 			RdQus:   s.Queue.SockRd,
 			WrQus:   s.Queue.SockWr,
+			Batch:   batch,
+			Delay:   delay,
 		}
-		return libol.NewTcpServer(s.Listen, c)
+		return libol.NewTcpServer(l.Listen, c)
 	case "udp":
 		c := &libol.UdpConfig{
 			Block:   config.GetBlock(s.Crypt),
+			Aead:    aead,
 			Timeout: time.Duration(s.Timeout) * time.Second,
 		}
-		return libol.NewUdpServer(s.Listen, c)
+		return libol.NewUdpServer(l.Listen, c)
 	case "ws":
 		c := &libol.WebConfig{
 			Block:   config.GetBlock(s.Crypt),
+			Aead:    aead,
 			Timeout: time.Duration(s.Timeout) * time.Second,
 			RdQus:   s.Queue.SockRd,
 			WrQus:   s.Queue.SockWr,
+			Batch:   batch,
+			Delay:   delay,
+			Path:    l.Path,
+			Hosts:   l.Host,
+			Origins: l.Origin,
 		}
-		return libol.NewWebServer(s.Listen, c)
+		if l.Path != "" {
+			return libol.NewWebServer(l.Path, c)
+		}
+		return libol.NewWebServer(l.Listen, c)
 	case "wss":
 		c := &libol.WebConfig{
 			Block:   config.GetBlock(s.Crypt),
+			Aead:    aead,
 			Timeout: time.Duration(s.Timeout) * time.Second,
 			RdQus:   s.Queue.SockRd,
 			WrQus:   s.Queue.SockWr,
+			Batch:   batch,
+			Delay:   delay,
 		}
 		if s.Cert != nil {
 			c.Cert = &libol.WebCert{
 				Crt: s.Cert.CrtFile,
 				Key: s.Cert.KeyFile,
 			}
+			c.Tls = s.Cert.GetTlsCfg()
 		}
-		return libol.NewWebServer(s.Listen, c)
+		return libol.NewWebServer(l.Listen, c)
 	default:
 		c := &libol.TcpConfig{
 			Block:   config.GetBlock(s.Crypt),
+			Aead:    aead,
 			Timeout: time.Duration(s.Timeout) * time.Second,
 			RdQus:   s.Queue.SockRd,
 			WrQus:   s.Queue.SockWr,
+			Batch:   batch,
+			Delay:   delay,
 		}
 		if s.Cert != nil {
 			c.Tls = s.Cert.GetTlsCfg()
 		}
-		return libol.NewTcpServer(s.Listen, c)
+		return libol.NewTcpServer(l.Listen, c)
 	}
 }
 
@@ -89,7 +112,8 @@ type Switch struct {
 	firewall *FireWall
 	hooks    []Hook
 	http     *Http
-	server   libol.SocketServer
+	servers  []libol.SocketServer
+	clients  sync.Map // server accepted client.
 	proxy    *Proxy
 	worker   map[string]*NetworkWorker
 	uuid     string
@@ -98,12 +122,19 @@ type Switch struct {
 }
 
 func NewSwitch(c config.Switch) *Switch {
-	server := GetSocketServer(c)
+	servers := make([]libol.SocketServer, 0, 4)
+	for _, l := range c.GetListeners() {
+		s := GetSocketServer(c, l)
+		if c.Keepalive != nil {
+			s.SetKeepalive(int64(c.Keepalive.Interval), int64(c.Keepalive.Timeout))
+		}
+		servers = append(servers, s)
+	}
 	v := Switch{
 		cfg:      c,
 		firewall: NewFireWall(c.FireWall),
 		worker:   make(map[string]*NetworkWorker, 32),
-		server:   server,
+		servers:  servers,
 		newTime:  time.Now().Unix(),
 		proxy:    NewProxy(c.Proxy),
 		hooks:    make([]Hook, 0, 64),
@@ -233,6 +264,17 @@ func (v *Switch) Initialize() {
 	if v.cfg.Http != nil {
 		v.http = NewHttp(v, v.cfg)
 	}
+	for _, s := range v.servers {
+		ws, ok := s.(*libol.WebServer)
+		if !ok || ws.Path() == "" {
+			continue
+		}
+		if v.http == nil {
+			v.out.Warn("Switch.Initialize: %s notMounted without http", ws.Path())
+			continue
+		}
+		v.http.Mount(ws.Path(), ws.Handler())
+	}
 	v.initNetwork()
 	// Controller
 	v.initCtrl()
@@ -313,10 +355,11 @@ func (v *Switch) ReadClient(client libol.SocketClient, frame *libol.FrameMessage
 	if !ok {
 		return libol.NewErr("point %s notRight.", addr)
 	}
-	device := point.Device
+	device := point.Joined(frame.Channel())
 	if point == nil || device == nil {
 		return libol.NewErr("Tap devices is nil")
 	}
+	libol.ClampMss(frame.Frame()[:frame.Size()], client.Mtu())
 	if _, err := device.Write(frame.Frame()); err != nil {
 		v.out.Error("Switch.ReadClient: %s", err)
 		return err
@@ -349,14 +392,23 @@ func (v *Switch) Start() {
 	for _, w := range v.worker {
 		w.Start(v)
 	}
-	// start server for accessing
-	libol.Go(v.server.Accept)
-	call := libol.ServerListener{
-		OnClient: v.OnClient,
-		OnClose:  v.OnClose,
-		ReadAt:   v.ReadClient,
+	// start servers for accessing, and records server of every client.
+	for _, s := range v.servers {
+		server := s
+		call := libol.ServerListener{
+			OnClient: func(client libol.SocketClient) error {
+				v.clients.Store(client, server)
+				return v.OnClient(client)
+			},
+			OnClose: func(client libol.SocketClient) error {
+				v.clients.Delete(client)
+				return v.OnClose(client)
+			},
+			ReadAt: v.ReadClient,
+		}
+		libol.Go(server.Accept)
+		libol.Go(func() { server.Loop(call) })
 	}
-	libol.Go(func() { v.server.Loop(call) })
 	if v.http != nil {
 		libol.Go(v.http.Start)
 	}
@@ -388,7 +440,9 @@ func (v *Switch) Stop() {
 		v.http.Shutdown()
 		v.http = nil
 	}
-	v.server.Close()
+	for _, s := range v.servers {
+		s.Close()
+	}
 	// stop network.
 	for _, w := range v.worker {
 		w.Stop()
@@ -403,8 +457,8 @@ func (v *Switch) UpTime() int64 {
 	return time.Now().Unix() - v.newTime
 }
 
-func (v *Switch) Server() libol.SocketServer {
-	return v.server
+func (v *Switch) Servers() []libol.SocketServer {
+	return v.servers
 }
 
 func (v *Switch) GetBridge(tenant string) (network.Bridger, error) {
@@ -500,7 +554,9 @@ func (v *Switch) ReadTap(device network.Taper, readAt func(f *libol.FrameMessage
 	for {
 		select {
 		case frame := <-queue:
-			if err := readAt(frame); err != nil {
+			err := readAt(frame)
+			frame.Free()
+			if err != nil {
 				v.out.Error("Switch.ReadTap: readAt %s %s", name, err)
 				return
 			}
@@ -512,8 +568,9 @@ func (v *Switch) ReadTap(device network.Taper, readAt func(f *libol.FrameMessage
 
 func (v *Switch) OffClient(client libol.SocketClient) {
 	v.out.Info("Switch.OffClient: %s", client)
-	if v.server != nil {
-		v.server.OffClient(client)
+	// only the server accepted it will close it.
+	if s, ok := v.clients.Load(client); ok {
+		s.(libol.SocketServer).OffClient(client)
 	}
 }
 
@@ -62,6 +62,8 @@ func (w *NetworkWorker) Initialize() {
 		IpEnd:   w.cfg.Subnet.End,
 		Netmask: w.cfg.Subnet.Netmask,
 		IfAddr:  w.cfg.Bridge.Address,
+		IfAddr6: w.cfg.Bridge.Address6,
+		Prefix6: w.cfg.Subnet.Prefix6,
 		Routes:  make([]*models.Route, 0, 2),
 	}
 	for _, rt := range w.cfg.Routes {
@@ -131,12 +133,13 @@ func (w *NetworkWorker) LoadRoutes() {
 	// install routes
 	w.out.Debug("NetworkWorker.LoadRoute: %v", w.cfg.Routes)
 	ifAddr := strings.SplitN(w.cfg.Bridge.Address, "/", 2)[0]
+	ifAddr6 := strings.SplitN(w.cfg.Bridge.Address6, "/", 2)[0]
 	link, err := netlink.LinkByName(w.bridge.Name())
-	if ifAddr == "" || err != nil {
+	if (ifAddr == "" && ifAddr6 == "") || err != nil {
 		return
 	}
 	for _, rt := range w.cfg.Routes {
-		if ifAddr == rt.NextHop { // route's next-hop is local not install again.
+		if ifAddr == rt.NextHop || ifAddr6 == rt.NextHop { // route's next-hop is local not install again.
 			continue
 		}
 		_, dst, err := net.ParseCIDR(rt.Prefix)
@@ -160,7 +163,7 @@ func (w *NetworkWorker) LoadRoutes() {
 
 func (w *NetworkWorker) UnLoadRoutes() {
 	link, err := netlink.LinkByName(w.bridge.Name())
-	if w.cfg.Bridge.Address == "" || err != nil {
+	if (w.cfg.Bridge.Address == "" && w.cfg.Bridge.Address6 == "") || err != nil {
 		return
 	}
 	for _, rt := range w.cfg.Routes {
@@ -185,6 +188,9 @@ func (w *NetworkWorker) UnLoadRoutes() {
 
 func (w *NetworkWorker) UpBridge(cfg config.Bridge) {
 	w.bridge.Open(cfg.Address)
+	if cfg.Address6 != "" {
+		w.addAddr6(cfg.Address6)
+	}
 	if cfg.Stp == "on" {
 		if err := w.bridge.Stp(true); err != nil {
 			w.out.Warn("NetworkWorker.Start: Stp %s", err)
@@ -197,6 +203,22 @@ func (w *NetworkWorker) UpBridge(cfg config.Bridge) {
 	}
 }
 
+func (w *NetworkWorker) addAddr6(addr string) {
+	link, err := netlink.LinkByName(w.bridge.Kernel())
+	if err != nil {
+		w.out.Error("NetworkWorker.addAddr6: %s", err)
+		return
+	}
+	ipAddr, err := netlink.ParseAddr(addr)
+	if err != nil {
+		w.out.Error("NetworkWorker.addAddr6: %s", err)
+		return
+	}
+	if err := netlink.AddrAdd(link, ipAddr); err != nil {
+		w.out.Warn("NetworkWorker.addAddr6: %s", err)
+	}
+}
+
 func (w *NetworkWorker) UpPeer(cfg config.Bridge) {
 	if cfg.Peer == "" {
 		return